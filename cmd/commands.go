@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+	"gitlab.com/AlexJarrah/media-manager/internal/monitor"
+	"gitlab.com/AlexJarrah/media-manager/internal/providers/lastfm"
+	"gitlab.com/AlexJarrah/media-manager/internal/scrobbler"
+)
+
+const usage = `Usage:
+  media-manager              Monitor media players
+  media-manager auth lastfm  Log in to Last.fm in the browser
+  media-manager auth <name>  Log in to a Last.fm-compatible scrobbler from the config
+  media-manager record <file>
+                             Monitor media players, recording their signals to a file
+  media-manager replay [-speed n] <file>
+                             Replay recorded signals into a temporary database, without scrobbling`
+
+// Run a CLI subcommand
+func runCommand(args []string) error {
+	switch args[0] {
+	case "auth":
+		return authCommand(args[1:])
+	case "record":
+		return recordCommand(args[1:])
+	case "replay":
+		return replayCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command: %s\n\n%s", args[0], usage)
+	}
+}
+
+// Authenticate with a service and store its session key
+func authCommand(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing service to authenticate with\n\n" + usage)
+	}
+
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		return err
+	}
+
+	lastFM, err := scrobbler.LastFMTarget(config, args[0])
+	if err != nil {
+		return err
+	}
+
+	if lastFM.APIKey == "" || lastFM.APISecret == "" {
+		return fmt.Errorf("set api_key and api_secret for %s in config.json first", args[0])
+	}
+
+	if _, err = lastfm.WebAuthenticate(lastFM, os.Stdout); err != nil {
+		return err
+	}
+
+	fmt.Printf("Logged in to %s, the session key has been saved.\n", args[0])
+	return nil
+}
+
+// Monitor players as usual while recording their signals, e.g. to attach to a bug report
+func recordCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("missing file to record to\n\n" + usage)
+	}
+
+	f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return runMonitor(f)
+}
+
+// Feed recorded signals through the monitor, without touching the real database or services
+func replayCommand(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	speed := flags.Float64("speed", 1, "replay this many times faster than recorded")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("missing recording to replay\n\n" + usage)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dir, err := os.MkdirTemp("", "media-manager-replay-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	// Only the recorded tracks are needed, not the library
+	database.Path = filepath.Join(dir, "data.db")
+	if err = database.InitializeSchema(); err != nil {
+		return err
+	}
+
+	scrobbler.Offline = true
+	log.SetOutput(os.Stderr)
+
+	if err = monitor.Replay(f, *speed); err != nil {
+		return err
+	}
+
+	db, err := database.NewDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	listens, err := db.GetListens("")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Replayed %d listens\n", len(listens))
+	return nil
+}
@@ -0,0 +1,87 @@
+// Fake MPRIS player for testing media-manager without a real player.
+//
+//	fake-mpris [-name fake] [-speed 1] [-private] script.jsonl
+//
+// Scripts have one JSON step per line, e.g.
+//
+//	{"action": "track", "title": "Song", "artists": ["Artist"], "length": 200}
+//	{"action": "play"}
+//	{"action": "wait", "seconds": 120}
+//	{"action": "seek", "position": 180}
+//	{"action": "quit"}
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/fakempris"
+)
+
+func main() {
+	name := flag.String("name", "fake", "player name, registered as org.mpris.MediaPlayer2.<name>")
+	speed := flag.Float64("speed", 1, "run the script this many times faster")
+	private := flag.Bool("private", false, "start a private session bus & print its address")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: fake-mpris [-name fake] [-speed 1] [-private] <script.jsonl | ->")
+		os.Exit(2)
+	}
+
+	// Exit only once run returned, so its deferred cleanup stops the private bus
+	if err := run(flag.Arg(0), *name, *speed, *private); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(path, name string, speed float64, private bool) error {
+	script := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		script = f
+	}
+
+	steps, err := fakempris.ReadScript(script)
+	if err != nil {
+		return err
+	}
+
+	var conn *dbus.Conn
+	if private {
+		session, err := fakempris.StartSession()
+		if err != nil {
+			return err
+		}
+		defer session.Close()
+
+		// Other tools join the session through this address
+		fmt.Printf("DBUS_SESSION_BUS_ADDRESS=%s\n", session.Address)
+
+		conn, err = session.Connect()
+		if err != nil {
+			return err
+		}
+	} else {
+		conn, err = dbus.SessionBus()
+		if err != nil {
+			return err
+		}
+	}
+	defer conn.Close()
+
+	player, err := fakempris.NewPlayer(conn, name, speed)
+	if err != nil {
+		return err
+	}
+
+	return player.Run(steps)
+}
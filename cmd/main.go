@@ -1,13 +1,19 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"os"
 
 	"github.com/godbus/dbus/v5"
 
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
 	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/discord"
 	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
 	"gitlab.com/AlexJarrah/media-manager/internal/monitor"
+	"gitlab.com/AlexJarrah/media-manager/internal/scrobbler"
 )
 
 func main() {
@@ -15,17 +21,36 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if err := database.Initialize(); err != nil {
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := runMonitor(nil); err != nil {
 		log.Fatal(err)
 	}
+}
+
+// Monitor players on the session bus, recording their signals to record when set
+func runMonitor(record io.Writer) error {
+	if err := database.Initialize(); err != nil {
+		return err
+	}
+
+	go scrobbler.ProcessQueue()
+	go discord.Run()
 
 	conn, err := dbus.SessionBus()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer conn.Close()
 
-	if err = monitor.MonitorPlayers(conn); err != nil {
-		log.Fatal(err)
+	if record != nil {
+		return monitor.Record(bus.Wrap(conn), record)
 	}
+	return monitor.MonitorPlayers(bus.Wrap(conn))
 }
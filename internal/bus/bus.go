@@ -0,0 +1,75 @@
+package bus
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// MPRIS object path & player interface
+const (
+	MPRISPath       = dbus.ObjectPath("/org/mpris/MediaPlayer2")
+	PlayerIface     = "org.mpris.MediaPlayer2.Player"
+	MPRISPrefix     = "org.mpris.MediaPlayer2."
+	PropertiesIface = "org.freedesktop.DBus.Properties"
+)
+
+// Pseudo-signal in recordings for a property the monitor read, sent by the player's well-known name.
+// Body: property, value
+const PropertyReadSignal = "media-manager.PropertyRead"
+
+// The parts of the session bus used to follow players, so they can be replaced with a fake
+type Bus interface {
+	// Deliver received signals to the channel
+	Signal(ch chan<- *dbus.Signal)
+	AddMatchSignal(options ...dbus.MatchOption) error
+
+	// List all names registered on the bus
+	ListNames() ([]string, error)
+	// Get the unique name owning a well-known name
+	GetNameOwner(name string) (string, error)
+
+	// Read a property of the MPRIS object, e.g. org.mpris.MediaPlayer2.Player.Position
+	GetProperty(name, property string) (dbus.Variant, error)
+	// Read all properties of an interface of the MPRIS object
+	GetAllProperties(name, iface string) (map[string]dbus.Variant, error)
+}
+
+type conn struct {
+	conn *dbus.Conn
+}
+
+// Use a D-Bus connection, e.g. dbus.SessionBus(), as a Bus
+func Wrap(c *dbus.Conn) Bus {
+	return &conn{conn: c}
+}
+
+func (c *conn) Signal(ch chan<- *dbus.Signal) {
+	c.conn.Signal(ch)
+}
+
+func (c *conn) AddMatchSignal(options ...dbus.MatchOption) error {
+	return c.conn.AddMatchSignal(options...)
+}
+
+func (c *conn) ListNames() (names []string, err error) {
+	err = c.conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names)
+	return names, err
+}
+
+func (c *conn) GetNameOwner(name string) (owner string, err error) {
+	err = c.conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, name).Store(&owner)
+	if err != nil {
+		return "", fmt.Errorf("failed to get name owner: %v", err)
+	}
+	return owner, nil
+}
+
+func (c *conn) GetProperty(name, property string) (dbus.Variant, error) {
+	return c.conn.Object(name, MPRISPath).GetProperty(property)
+}
+
+func (c *conn) GetAllProperties(name, iface string) (props map[string]dbus.Variant, err error) {
+	err = c.conn.Object(name, MPRISPath).Call(PropertiesIface+".GetAll", 0, iface).Store(&props)
+	return props, err
+}
@@ -0,0 +1,235 @@
+package bus
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// In-memory bus for driving the monitor with scripted players, without a D-Bus daemon
+type Fake struct {
+	mu         sync.Mutex
+	channels   []chan<- *dbus.Signal
+	owners     map[string]string                  // Unique names by well-known name
+	properties map[string]map[string]dbus.Variant // Properties by well-known name, keyed as interface.property
+	nextOwner  int
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		owners:     make(map[string]string),
+		properties: make(map[string]map[string]dbus.Variant),
+	}
+}
+
+func (f *Fake) Signal(ch chan<- *dbus.Signal) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.channels = append(f.channels, ch)
+}
+
+// Every signal is delivered, so match rules are not needed
+func (f *Fake) AddMatchSignal(options ...dbus.MatchOption) error {
+	return nil
+}
+
+func (f *Fake) ListNames() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	names := []string{"org.freedesktop.DBus"}
+	for name := range f.owners {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f *Fake) GetNameOwner(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if owner, ok := f.owners[name]; ok {
+		return owner, nil
+	}
+	if strings.HasPrefix(name, ":") {
+		return name, nil
+	}
+	return "", fmt.Errorf("failed to get name owner: name %s has no owner", name)
+}
+
+func (f *Fake) GetProperty(name, property string) (dbus.Variant, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if v, ok := f.properties[name][property]; ok {
+		return v, nil
+	}
+	return dbus.Variant{}, fmt.Errorf("no property %s on %s", property, name)
+}
+
+func (f *Fake) GetAllProperties(name, iface string) (map[string]dbus.Variant, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	props, ok := f.properties[name]
+	if !ok {
+		return nil, fmt.Errorf("no object %s on %s", MPRISPath, name)
+	}
+
+	all := make(map[string]dbus.Variant)
+	for key, v := range props {
+		if property, ok := strings.CutPrefix(key, iface+"."); ok {
+			all[property] = v
+		}
+	}
+	return all, nil
+}
+
+// Register a player, announcing it with NameOwnerChanged. Returns its unique name.
+func (f *Fake) AddPlayer(name string) string {
+	f.mu.Lock()
+	f.nextOwner++
+	owner := fmt.Sprintf(":1.%d", f.nextOwner)
+	f.owners[name] = owner
+	f.properties[name] = make(map[string]dbus.Variant)
+	f.mu.Unlock()
+
+	f.Emit(&dbus.Signal{
+		Sender: "org.freedesktop.DBus",
+		Path:   "/org/freedesktop/DBus",
+		Name:   "org.freedesktop.DBus.NameOwnerChanged",
+		Body:   []interface{}{name, "", owner},
+	})
+	return owner
+}
+
+// Unregister a player, announcing it with NameOwnerChanged
+func (f *Fake) RemovePlayer(name string) {
+	f.mu.Lock()
+	owner := f.owners[name]
+	delete(f.owners, name)
+	delete(f.properties, name)
+	f.mu.Unlock()
+
+	f.Emit(&dbus.Signal{
+		Sender: "org.freedesktop.DBus",
+		Path:   "/org/freedesktop/DBus",
+		Name:   "org.freedesktop.DBus.NameOwnerChanged",
+		Body:   []interface{}{name, owner, ""},
+	})
+}
+
+// Change player properties & emit PropertiesChanged, e.g. for Metadata or PlaybackStatus
+func (f *Fake) SetProperties(name string, props map[string]dbus.Variant) {
+	f.mu.Lock()
+	owner := f.owners[name]
+	for property, v := range props {
+		if f.properties[name] != nil {
+			f.properties[name][PlayerIface+"."+property] = v
+		}
+	}
+	f.mu.Unlock()
+
+	f.Emit(&dbus.Signal{
+		Sender: owner,
+		Path:   MPRISPath,
+		Name:   PropertiesIface + ".PropertiesChanged",
+		Body:   []interface{}{PlayerIface, maps.Clone(props), []string{}},
+	})
+}
+
+// Change a player property without emitting a signal, as players do for Position
+func (f *Fake) SetQuietProperty(name, property string, v dbus.Variant) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.properties[name] != nil {
+		f.properties[name][PlayerIface+"."+property] = v
+	}
+}
+
+// Move a player to a position in microseconds & emit Seeked
+func (f *Fake) Seek(name string, position int64) {
+	f.SetQuietProperty(name, "Position", dbus.MakeVariant(position))
+
+	f.mu.Lock()
+	owner := f.owners[name]
+	f.mu.Unlock()
+
+	f.Emit(&dbus.Signal{
+		Sender: owner,
+		Path:   MPRISPath,
+		Name:   PlayerIface + ".Seeked",
+		Body:   []interface{}{position},
+	})
+}
+
+// Deliver a recorded signal, first applying its changes to names & properties so later reads match
+func (f *Fake) Replay(sig *dbus.Signal) {
+	f.mu.Lock()
+	switch sig.Name {
+	case PropertyReadSignal:
+		// Answers to the monitor's reads are stored for it, but are not signals
+		if props := f.properties[sig.Sender]; props != nil && len(sig.Body) == 2 {
+			property, _ := sig.Body[0].(string)
+			v, _ := sig.Body[1].(dbus.Variant)
+			props[property] = v
+		}
+		f.mu.Unlock()
+		return
+	case "org.freedesktop.DBus.NameOwnerChanged":
+		if len(sig.Body) == 3 {
+			name, _ := sig.Body[0].(string)
+			newOwner, _ := sig.Body[2].(string)
+			if newOwner == "" {
+				delete(f.owners, name)
+				delete(f.properties, name)
+			} else {
+				f.owners[name] = newOwner
+				if f.properties[name] == nil {
+					f.properties[name] = make(map[string]dbus.Variant)
+				}
+			}
+		}
+	case PropertiesIface + ".PropertiesChanged":
+		if props, ok := f.senderProperties(sig); ok && len(sig.Body) >= 2 {
+			iface, _ := sig.Body[0].(string)
+			changed, _ := sig.Body[1].(map[string]dbus.Variant)
+			for property, v := range changed {
+				props[iface+"."+property] = v
+			}
+		}
+	case PlayerIface + ".Seeked":
+		if props, ok := f.senderProperties(sig); ok && len(sig.Body) == 1 {
+			props[PlayerIface+".Position"] = dbus.MakeVariant(sig.Body[0])
+		}
+	}
+	f.mu.Unlock()
+
+	f.Emit(sig)
+}
+
+// Properties of the player that sent a signal; requires f.mu
+func (f *Fake) senderProperties(sig *dbus.Signal) (map[string]dbus.Variant, bool) {
+	for name, owner := range f.owners {
+		if owner == sig.Sender {
+			return f.properties[name], f.properties[name] != nil
+		}
+	}
+	return nil, false
+}
+
+// Deliver a signal as if it was received from the bus
+func (f *Fake) Emit(sig *dbus.Signal) {
+	f.mu.Lock()
+	channels := append([]chan<- *dbus.Signal(nil), f.channels...)
+	f.mu.Unlock()
+
+	for _, ch := range channels {
+		ch <- sig
+	}
+}
@@ -90,7 +90,8 @@ func (db *DB) LoadTracksFromDirectory(dirPath string, mode uint8) error {
 			if artist != nil {
 				artists[artist.Name] = artist
 			}
-			if album != nil {
+			// Tracks without an album name have no album
+			if album != nil && album.Name != "" {
 				albums[album.Name] = album
 			}
 		}()
@@ -143,15 +144,22 @@ func (db *DB) LoadTracksFromDirectory(dirPath string, mode uint8) error {
 		return err
 	}
 
+	// Albums are merged by name while scanning
+	for _, t := range tracks {
+		if v, exists := albums[t.Album.Name]; exists {
+			t.Album.ID = v.ID
+		}
+	}
+
 	if mode == AddNewTracks {
-		err = db.AddTracks(tracks)
-		return err
+		if err = db.AddTracks(tracks); err != nil {
+			return err
+		}
+
+		// Listens of tracks played before they were added to the library are kept
+		return db.MergeRemoteTracks(tracks)
 	} else {
 		for _, t := range tracks {
-			if v, exists := albums[t.Album.Name]; exists {
-				t.Album.ID = v.ID
-			}
-
 			keys := []string{
 				"name",
 				"duration",
@@ -1,6 +1,20 @@
 package database
 
-import _ "embed"
+import "embed"
 
 //go:embed init.sql
 var sqlInit string
+
+// Schema changes applied on top of init.sql, in file name order
+//
+//go:embed migrations/*.sql
+var sqlMigrations embed.FS
+
+// Scrobble statuses
+const (
+	// Waiting to be submitted or retried
+	ScrobblePending = "pending"
+
+	// Rejected by the service, kept for inspection
+	ScrobbleRejected = "rejected"
+)
@@ -3,15 +3,15 @@ package database
 import "gitlab.com/AlexJarrah/media-manager/internal/filesystem"
 
 func Initialize() error {
-	db, err := NewDB()
-	if err != nil {
+	if err := InitializeSchema(); err != nil {
 		return err
 	}
-	defer db.Close()
 
-	if _, err = db.Exec(sqlInit); err != nil {
+	db, err := NewDB()
+	if err != nil {
 		return err
 	}
+	defer db.Close()
 
 	config, err := filesystem.GetConfigFile()
 	if err != nil {
@@ -26,3 +26,18 @@ func Initialize() error {
 
 	return nil
 }
+
+// Create the tables & apply the migrations, without loading any tracks
+func InitializeSchema() error {
+	db, err := NewDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err = db.Exec(sqlInit); err != nil {
+		return err
+	}
+
+	return db.Migrate()
+}
@@ -63,6 +63,23 @@ CREATE TABLE IF NOT EXISTS listens (
     FOREIGN KEY (track_id) REFERENCES tracks (track_id)
 );
 
+-- Scrobble queue table
+CREATE TABLE IF NOT EXISTS scrobbles (
+    scrobble_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    service TEXT NOT NULL, -- Service the scrobble is submitted to
+    artist TEXT NOT NULL,
+    track TEXT NOT NULL,
+    album TEXT,
+    mbid TEXT,
+    duration INTEGER, -- seconds
+    timestamp DATETIME NOT NULL, -- Time the track started playing
+    status TEXT NOT NULL DEFAULT 'pending', -- pending or rejected
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt DATETIME DEFAULT CURRENT_TIMESTAMP,
+    error TEXT, -- Last submission error or rejection reason
+    UNIQUE (service, artist, track, timestamp)
+);
+
 -- Tags table
 CREATE TABLE IF NOT EXISTS tags (
     tag_id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Ways a player's track can be matched to a library track, most reliable first
+const (
+	MatchFilePath = "file_path" // The player's file:// URL is the track's file
+	MatchMetadata = "metadata"  // Title, artists & album agree after normalizing
+	MatchDuration = "duration"  // Only the title & duration agree
+	MatchRemote   = "remote"    // Not in the library, a remote track was added
+)
+
+// Tracks whose durations differ by at most this many seconds are considered equal
+const durationTolerance = 3
+
+// TrackQuery describes a track reported by a player, to find it in the library
+type TrackQuery struct {
+	FilePath string // Local file path, if the player reported one
+	Title    string
+	Artists  []string
+	Album    string
+	Duration int // seconds, 0 if unknown
+}
+
+// TrackMatch describes how a library track was matched
+type TrackMatch struct {
+	Method     string  `json:"method"`
+	Confidence float64 `json:"confidence"` // From 0 to 1
+}
+
+// MatchTrack finds the library track a player is playing: by file path first,
+// then by normalized title, artists & album, then by title & duration.
+// Returns nil when no track matches.
+func (db *DB) MatchTrack(query TrackQuery) (*Track, TrackMatch, error) {
+	if query.FilePath != "" {
+		tracks, err := db.GetTracks("WHERE t.file_path = ?", query.FilePath)
+		if err != nil {
+			return nil, TrackMatch{}, err
+		}
+		if len(tracks) > 0 {
+			return tracks[0], TrackMatch{Method: MatchFilePath, Confidence: 1}, nil
+		}
+	}
+
+	candidates, err := db.tracksByTitle(query.Title, "")
+	if err != nil || len(candidates) == 0 {
+		return nil, TrackMatch{}, err
+	}
+
+	var best *Track
+	var bestMatch TrackMatch
+	for _, track := range candidates {
+		match := compareTrack(track, query)
+		if match.Confidence == 0 {
+			continue
+		}
+
+		// Library tracks win ties, remote ones may be duplicates of them
+		better := match.Confidence > bestMatch.Confidence ||
+			match.Confidence == bestMatch.Confidence && best.IsRemote() && !track.IsRemote()
+		if better {
+			best, bestMatch = track, match
+		}
+	}
+	return best, bestMatch, nil
+}
+
+// Returns the tracks whose normalized name equals the title's, optionally
+// filtered by another condition on t
+func (db *DB) tracksByTitle(title, condition string) ([]*Track, error) {
+	title = normalize(title)
+	if title == "" {
+		return nil, nil
+	}
+
+	if err := db.normalizeTrackNames(); err != nil {
+		return nil, err
+	}
+
+	if condition != "" {
+		condition = " AND " + condition
+	}
+	return db.GetTracks("WHERE t.normalized_name = ?"+condition, title)
+}
+
+// Fill in the normalized names of tracks that were added or renamed since
+func (db *DB) normalizeTrackNames() error {
+	rows, err := db.Query("SELECT track_id, name FROM tracks WHERE normalized_name IS NULL")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	names := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err = rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		names[id] = name
+	}
+	if err = rows.Err(); err != nil || len(names) == 0 {
+		return err
+	}
+	rows.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Tracks renamed since they were read are left for next time
+	stmt, err := tx.Prepare("UPDATE tracks SET normalized_name = ? WHERE track_id = ? AND name = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for id, name := range names {
+		if _, err = stmt.Exec(normalize(name), id, name); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Rate how well a track with the query's title matches the rest of the query.
+// Fields missing on either side lower the confidence without ruling the
+// track out; conflicting fields leave only a duration match.
+func compareTrack(track *Track, query TrackQuery) TrackMatch {
+	artists, artistsKnown := compareArtists(track.Artists, query.Artists)
+
+	albumKnown := track.Album.Name != "" && query.Album != ""
+	album := normalize(track.Album.Name) == normalize(query.Album)
+
+	durationKnown := track.Duration > 0 && query.Duration > 0
+	duration := durationsMatch(track.Duration, query.Duration)
+
+	if (artists || !artistsKnown) && (album || !albumKnown) && (duration || !durationKnown) {
+		confidence := 0.6
+		if artistsKnown {
+			confidence += 0.2
+		}
+		if albumKnown {
+			confidence += 0.1
+		}
+		if durationKnown {
+			confidence += 0.1
+		}
+		return TrackMatch{Method: MatchMetadata, Confidence: confidence}
+	}
+
+	if durationKnown && duration {
+		return TrackMatch{Method: MatchDuration, Confidence: 0.5}
+	}
+	return TrackMatch{}
+}
+
+// Reports whether any artist is shared, and whether both sides have artists
+func compareArtists(library []Artist, artists []string) (match, known bool) {
+	if len(library) == 0 || len(artists) == 0 {
+		return false, false
+	}
+
+	for _, a := range library {
+		for _, artist := range artists {
+			if normalize(a.Name) == normalize(artist) {
+				return true, true
+			}
+		}
+	}
+	return false, true
+}
+
+func durationsMatch(a, b int) bool {
+	diff := a - b
+	return diff >= -durationTolerance && diff <= durationTolerance
+}
+
+// Normalize a name for comparison: case, punctuation & spacing are ignored,
+// and "&" is treated as "and"
+func normalize(name string) string {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("&", " and ", "'", "", "’", "").Replace(name)
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
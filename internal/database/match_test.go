@@ -0,0 +1,34 @@
+package database
+
+import "testing"
+
+func TestMatchTrackNormalizedName(t *testing.T) {
+	db := newTestDB(t)
+
+	track, err := db.AddRemoteTrack(TrackQuery{Title: "Don't Stop (Live)", Artists: []string{"Band"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found, _, err := db.MatchTrack(TrackQuery{Title: "dont stop - live", Artists: []string{"Band"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found == nil || found.ID != track.ID {
+		t.Fatalf("got %v, want track %d", found, track.ID)
+	}
+
+	// Renaming resets the normalized name, so the old title no longer matches
+	if _, err = db.Exec("UPDATE tracks SET name = ? WHERE track_id = ?", "Go On", track.ID); err != nil {
+		t.Fatal(err)
+	}
+	for title, want := range map[string]bool{"Don't Stop (Live)": false, "go on": true} {
+		found, _, err := db.MatchTrack(TrackQuery{Title: title, Artists: []string{"Band"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (found != nil) != want {
+			t.Errorf("%q: got %v, want found %v", title, found, want)
+		}
+	}
+}
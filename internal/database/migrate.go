@@ -0,0 +1,53 @@
+package database
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+)
+
+// Migrate applies schema migrations that have not yet been applied to the database.
+// The number of applied migrations is tracked in SQLite's user_version pragma.
+func (db *DB) Migrate() error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(sqlMigrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for i := version; i < len(files); i++ {
+		migration, err := fs.ReadFile(sqlMigrations, files[i])
+		if err != nil {
+			return err
+		}
+
+		if err = db.applyMigration(string(migration), i+1); err != nil {
+			return fmt.Errorf("failed to apply migration %s: %v", files[i], err)
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) applyMigration(migration string, version int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(migration); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,3 @@
+-- Source player & origin URL, submitted to services that accept them
+ALTER TABLE scrobbles ADD COLUMN player TEXT;
+ALTER TABLE scrobbles ADD COLUMN origin_url TEXT;
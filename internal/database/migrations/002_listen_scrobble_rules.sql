@@ -0,0 +1,2 @@
+-- JSON object of the scrobble rule that allowed or blocked the listen, by scrobbler
+ALTER TABLE listens ADD COLUMN scrobble_rules TEXT;
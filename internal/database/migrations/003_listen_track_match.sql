@@ -0,0 +1,4 @@
+-- How the listen's track was found in the library: file_path, metadata or duration
+ALTER TABLE listens ADD COLUMN match_method TEXT;
+-- Confidence of the track match, from 0 to 1
+ALTER TABLE listens ADD COLUMN match_confidence REAL;
@@ -0,0 +1,24 @@
+-- Tracks played from streams & phones have no file, so file_path & sha256sum
+-- become optional, and album_id too for tracks played without an album name.
+-- SQLite cannot drop NOT NULL constraints, so the table is rebuilt.
+CREATE TABLE tracks_new (
+    track_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    album_id INTEGER, -- NULL for tracks without an album
+    name TEXT NOT NULL,
+    duration INTEGER NOT NULL, -- seconds
+    lyrics TEXT,
+    is_explicit BOOLEAN DEFAULT 0,
+    file_path TEXT UNIQUE, -- Path to the track file, NULL for remote tracks
+    sha256sum TEXT UNIQUE, -- SHA-256 checksum of the track file, NULL for remote tracks
+    FOREIGN KEY (album_id) REFERENCES albums (album_id)
+);
+
+INSERT INTO tracks_new (track_id, album_id, name, duration, lyrics, is_explicit, file_path, sha256sum)
+SELECT track_id, album_id, name, duration, lyrics, is_explicit, file_path, sha256sum FROM tracks;
+
+DROP TABLE tracks;
+ALTER TABLE tracks_new RENAME TO tracks;
+
+-- Detach remote tracks from a shared album without a name
+UPDATE tracks SET album_id = NULL
+WHERE file_path IS NULL AND album_id IN (SELECT album_id FROM albums WHERE name = '');
@@ -0,0 +1,10 @@
+-- Context of each listen, to audit track matches & build statistics
+ALTER TABLE listens ADD COLUMN player TEXT; -- MPRIS name of the player
+ALTER TABLE listens ADD COLUMN start_time DATETIME;
+ALTER TABLE listens ADD COLUMN end_time DATETIME;
+ALTER TABLE listens ADD COLUMN seek_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE listens ADD COLUMN is_skip BOOLEAN NOT NULL DEFAULT 0; -- Left before the end of the track
+ALTER TABLE listens ADD COLUMN metadata TEXT; -- JSON object of the player's MPRIS metadata
+ALTER TABLE listens ADD COLUMN scrobbled_services TEXT; -- JSON array of services that accepted the scrobble
+-- Listen a scrobble was queued for, to mark it as scrobbled once accepted
+ALTER TABLE scrobbles ADD COLUMN listen_id INTEGER REFERENCES listens (listen_id);
@@ -0,0 +1,10 @@
+-- Track names normalized for matching, so candidates are found by index.
+-- SQLite cannot normalize them, so they are filled in by the app: NULL until
+-- then, and reset to NULL when the name changes.
+ALTER TABLE tracks ADD COLUMN normalized_name TEXT;
+CREATE INDEX tracks_normalized_name ON tracks (normalized_name);
+
+CREATE TRIGGER tracks_name_changed AFTER UPDATE OF name ON tracks
+BEGIN
+    UPDATE tracks SET normalized_name = NULL WHERE track_id = NEW.track_id;
+END;
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+)
+
+// IsRemote reports whether the track was only played, e.g. from a stream or a
+// phone, and has no file in the library
+func (t *Track) IsRemote() bool {
+	return t.FilePath == ""
+}
+
+// AddRemoteTrack adds a track without a file from a player's metadata, so
+// listens of tracks outside the library are kept. Existing artists are reused
+// by name, and albums by name & artist.
+func (db *DB) AddRemoteTrack(query TrackQuery) (*Track, error) {
+	if query.Title == "" {
+		return nil, errors.New("No title to add the remote track by")
+	}
+
+	track := &Track{Name: query.Title, Duration: query.Duration}
+	for _, name := range query.Artists {
+		artist, err := db.artistByName(name)
+		if err != nil {
+			return nil, err
+		}
+		track.Artists = append(track.Artists, *artist)
+	}
+
+	// Tracks without an album name have no album, rather than sharing one
+	if query.Album != "" {
+		album, err := db.albumByName(query.Album, track.Artists)
+		if err != nil {
+			return nil, err
+		}
+		track.Album = *album
+	}
+
+	if err := db.AddTracks([]*Track{track}); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// Returns the artist with the name, adding it if there is none
+func (db *DB) artistByName(name string) (*Artist, error) {
+	artists, err := db.GetArtists("WHERE name = ? COLLATE NOCASE", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(artists) > 0 {
+		return artists[0], nil
+	}
+
+	artist := &Artist{Name: name}
+	if err = db.AddArtists([]*Artist{artist}); err != nil {
+		return nil, err
+	}
+	return artist, nil
+}
+
+// Returns the album with the name by any of the artists, adding it if there is
+// none. Albums of other artists with the same name, e.g. "Greatest Hits", are not reused.
+func (db *DB) albumByName(name string, artists []Artist) (*Album, error) {
+	albums, err := db.GetAlbums("WHERE name = ? COLLATE NOCASE", name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, album := range albums {
+		if sharesArtist(album.Artists, artists) {
+			return album, nil
+		}
+	}
+
+	album := &Album{Name: name, Artists: artists}
+	if err = db.AddAlbums([]*Album{album}); err != nil {
+		return nil, err
+	}
+	return album, nil
+}
+
+// Reports whether the lists share an artist by name. Two lists without artists
+// are considered equal.
+func sharesArtist(a, b []Artist) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+
+	for _, x := range a {
+		for _, y := range b {
+			if normalize(x.Name) == normalize(y.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MergeRemoteTracks replaces remote tracks with the library tracks they match,
+// e.g. once the user bought & scanned a track they streamed before. Listens,
+// tags & playlist entries move to the library track.
+func (db *DB) MergeRemoteTracks(tracks []*Track) error {
+	for _, track := range tracks {
+		if track.IsRemote() {
+			continue
+		}
+
+		query := TrackQuery{Title: track.Name, Album: track.Album.Name, Duration: track.Duration}
+		for _, artist := range track.Artists {
+			query.Artists = append(query.Artists, artist.Name)
+		}
+
+		remotes, err := db.tracksByTitle(track.Name, "t.file_path IS NULL")
+		if err != nil {
+			return err
+		}
+
+		for _, remote := range remotes {
+			// Only merge on agreeing metadata, a title & duration are too vague
+			if compareTrack(remote, query).Method != MatchMetadata {
+				continue
+			}
+
+			if err = db.mergeTrack(remote.ID, track.ID); err != nil {
+				return err
+			}
+			log.Printf("Merged remote track %s into %s\n", remote.Name, track.FilePath)
+		}
+	}
+
+	return nil
+}
+
+// Move everything referencing a track to another track, then delete it along
+// with its album & artists if nothing else uses them
+func (db *DB) mergeTrack(fromID, toID int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var albumID sql.NullInt64
+	if err = tx.QueryRow("SELECT album_id FROM tracks WHERE track_id = ?", fromID).Scan(&albumID); err != nil {
+		return err
+	}
+
+	// The album's artists too, as they may only be credited on the album
+	artistIDs, err := queryIDs(tx, `
+    SELECT artist_id FROM track_artists WHERE track_id = ?
+    UNION SELECT artist_id FROM album_artists WHERE album_id = ?
+  `, fromID, albumID)
+	if err != nil {
+		return err
+	}
+
+	statements := []string{
+		"UPDATE listens SET track_id = ? WHERE track_id = ?",
+		"UPDATE OR IGNORE track_tags SET track_id = ? WHERE track_id = ?",
+		"UPDATE OR IGNORE playlist_tracks SET track_id = ? WHERE track_id = ?",
+	}
+	for _, statement := range statements {
+		if _, err = tx.Exec(statement, toID, fromID); err != nil {
+			return err
+		}
+	}
+
+	// Rows left over were duplicates of the library track's
+	for _, table := range []string{"track_tags", "playlist_tracks", "track_artists", "tracks"} {
+		if _, err = tx.Exec("DELETE FROM "+table+" WHERE track_id = ?", fromID); err != nil {
+			return err
+		}
+	}
+
+	if albumID.Valid {
+		if _, err = tx.Exec(deleteUnusedAlbumArtists, albumID); err != nil {
+			return err
+		}
+		if _, err = tx.Exec(deleteUnusedAlbum, albumID); err != nil {
+			return err
+		}
+	}
+	for _, id := range artistIDs {
+		if _, err = tx.Exec(deleteUnusedArtist, id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Albums without tracks that are not tagged or in a playlist are only left
+// over from remote tracks
+const unusedAlbum = `
+    NOT EXISTS (SELECT 1 FROM tracks WHERE album_id = ?1)
+    AND NOT EXISTS (SELECT 1 FROM album_tags WHERE album_id = ?1)
+    AND NOT EXISTS (SELECT 1 FROM playlist_albums WHERE album_id = ?1)
+  `
+
+const (
+	deleteUnusedAlbumArtists = "DELETE FROM album_artists WHERE album_id = ?1 AND" + unusedAlbum
+	deleteUnusedAlbum        = "DELETE FROM albums WHERE album_id = ?1 AND" + unusedAlbum
+	deleteUnusedArtist       = `
+    DELETE FROM artists WHERE artist_id = ?1
+    AND NOT EXISTS (SELECT 1 FROM track_artists WHERE artist_id = ?1)
+    AND NOT EXISTS (SELECT 1 FROM album_artists WHERE artist_id = ?1)
+    AND NOT EXISTS (SELECT 1 FROM artist_tags WHERE artist_id = ?1)
+    AND NOT EXISTS (SELECT 1 FROM playlist_artists WHERE artist_id = ?1)
+  `
+)
+
+// Returns the IDs selected by the query
+func queryIDs(tx *sql.Tx, query string, args ...any) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Open an empty, fully migrated database in a temporary directory
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	Path = filepath.Join(t.TempDir(), "data.db")
+	t.Cleanup(func() { Path = "" })
+
+	db, err := NewDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err = db.Exec(sqlInit); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestAddRemoteTrackAlbums(t *testing.T) {
+	db := newTestDB(t)
+
+	// A library album of another artist with the same name
+	other := []*Artist{{Name: "Other"}}
+	if err := db.AddArtists(other); err != nil {
+		t.Fatal(err)
+	}
+	library := &Album{Name: "Greatest Hits", Artists: []Artist{*other[0]}}
+	if err := db.AddAlbums([]*Album{library}); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := db.AddRemoteTrack(TrackQuery{Title: "One", Artists: []string{"Band"}, Album: "Greatest Hits"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Album.ID == library.ID {
+		t.Errorf("remote track was added to the album of another artist")
+	}
+
+	second, err := db.AddRemoteTrack(TrackQuery{Title: "Two", Artists: []string{"band"}, Album: "greatest hits"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Album.ID != first.Album.ID {
+		t.Errorf("album %d of the same artist was not reused, got %d", first.Album.ID, second.Album.ID)
+	}
+	if second.Artists[0].ID != first.Artists[0].ID {
+		t.Errorf("artist %d was not reused, got %d", first.Artists[0].ID, second.Artists[0].ID)
+	}
+
+	for _, title := range []string{"Stream A", "Stream B"} {
+		track, err := db.AddRemoteTrack(TrackQuery{Title: title, Artists: []string{"Band"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tracks, err := db.GetTracks("WHERE t.track_id = ?", track.ID)
+		if err != nil || len(tracks) != 1 {
+			t.Fatalf("GetTracks(%d) = %v, %v", track.ID, tracks, err)
+		}
+		if tracks[0].Album.ID != 0 || !tracks[0].IsRemote() {
+			t.Errorf("%s: album = %d, remote = %v, want no album & remote", title, tracks[0].Album.ID, tracks[0].IsRemote())
+		}
+	}
+}
+
+func TestMergeRemoteTracks(t *testing.T) {
+	db := newTestDB(t)
+
+	remote, err := db.AddRemoteTrack(TrackQuery{Title: "Song", Artists: []string{"Band"}, Album: "Record", Duration: 200})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AddListens([]*Listen{{UserID: 1, TrackID: remote.ID, Timestamp: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	library := &Track{Name: "Song", FilePath: "/music/song.flac", SHA256Sum: "abc", Artists: remote.Artists, Album: Album{Name: "Record"}}
+	if err = db.AddTracks([]*Track{library}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.MergeRemoteTracks([]*Track{library}); err != nil {
+		t.Fatal(err)
+	}
+
+	listens, err := db.GetListens("")
+	if err != nil || len(listens) != 1 {
+		t.Fatalf("GetListens() = %v, %v", listens, err)
+	}
+	if listens[0].TrackID != library.ID {
+		t.Errorf("listen track = %d, want the library track %d", listens[0].TrackID, library.ID)
+	}
+
+	if tracks, _ := db.GetTracks("WHERE t.track_id = ?", remote.ID); len(tracks) != 0 {
+		t.Errorf("remote track was not removed")
+	}
+}
+
+func TestMergeRemoteTracksOrphans(t *testing.T) {
+	db := newTestDB(t)
+
+	remote, err := db.AddRemoteTrack(TrackQuery{Title: "Song", Artists: []string{"Band"}, Album: "Record"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The library spells the artist differently, so it has its own rows
+	artists := []*Artist{{Name: "The Band"}}
+	if err = db.AddArtists(artists); err != nil {
+		t.Fatal(err)
+	}
+	album := &Album{Name: "Record", Artists: []Artist{*artists[0]}}
+	if err = db.AddAlbums([]*Album{album}); err != nil {
+		t.Fatal(err)
+	}
+	library := &Track{Name: "Song", FilePath: "/music/song.flac", SHA256Sum: "abc", Artists: []Artist{*artists[0]}, Album: *album}
+	if err = db.AddTracks([]*Track{library}); err != nil {
+		t.Fatal(err)
+	}
+	if err = db.mergeTrack(remote.ID, library.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM albums WHERE album_id = ?", remote.Album.ID).Scan(&count)
+	if count != 0 {
+		t.Errorf("remote album %d was not removed", remote.Album.ID)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM artists WHERE artist_id = ?", remote.Artists[0].ID).Scan(&count)
+	if count != 0 {
+		t.Errorf("remote artist %d was not removed", remote.Artists[0].ID)
+	}
+	db.QueryRow("SELECT COUNT(*) FROM albums WHERE album_id = ?", album.ID).Scan(&count)
+	if count != 1 {
+		t.Errorf("library album %d was removed", album.ID)
+	}
+}
+
+func TestAddTracksAlbumArtists(t *testing.T) {
+	db := newTestDB(t)
+
+	other := []*Artist{{Name: "Other"}}
+	if err := db.AddArtists(other); err != nil {
+		t.Fatal(err)
+	}
+	library := &Album{Name: "Greatest Hits", Artists: []Artist{*other[0]}}
+	if err := db.AddAlbums([]*Album{library}); err != nil {
+		t.Fatal(err)
+	}
+
+	artists := []*Artist{{Name: "Band"}}
+	if err := db.AddArtists(artists); err != nil {
+		t.Fatal(err)
+	}
+	track := &Track{Name: "One", FilePath: "/music/one.flac", SHA256Sum: "one", Artists: []Artist{*artists[0]}, Album: Album{Name: "Greatest Hits"}}
+	if err := db.AddTracks([]*Track{track}); err != nil {
+		t.Fatal(err)
+	}
+
+	if track.Album.ID == 0 || track.Album.ID == library.ID {
+		t.Errorf("album = %d, want a new album apart from %d of another artist", track.Album.ID, library.ID)
+	}
+}
@@ -15,15 +15,20 @@ type DB struct {
 	*sql.DB
 }
 
+// Overrides the database location, e.g. to replay player sessions into a temporary database
+var Path string
+
 // NewDB creates a new database connection
 func NewDB() (*DB, error) {
-	dataDir, err := filesystem.GetDataDir()
-	if err != nil {
-		return nil, err
+	dbPath := Path
+	if dbPath == "" {
+		dataDir, err := filesystem.GetDataDir()
+		if err != nil {
+			return nil, err
+		}
+		dbPath = filepath.Join(dataDir, "data.db")
 	}
 
-	dbPath := filepath.Join(dataDir, "data.db")
-
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, err
@@ -55,8 +60,8 @@ type Track struct {
 	Duration   int            `json:"duration"`
 	Lyrics     sql.NullString `json:"lyrics"`
 	IsExplicit bool           `json:"is_explicit"`
-	FilePath   string         `json:"file_path"`
-	SHA256Sum  string         `json:"sha256sum"`
+	FilePath   string         `json:"file_path"` // Empty for remote tracks
+	SHA256Sum  string         `json:"sha256sum"` // Empty for remote tracks
 	Artists    []Artist       `json:"artists"`
 	Album      Album          `json:"album"`
 	Tags       []Tag          `json:"tags"`
@@ -71,11 +76,39 @@ type User struct {
 
 // Listen represents a listen event in the database
 type Listen struct {
-	ID         int64     `json:"id"`
-	UserID     int64     `json:"user_id"`
-	TrackID    int64     `json:"track_id"`
-	ListenTime int       `json:"listen_time"`
-	Timestamp  time.Time `json:"timestamp"`
+	ID                int64             `json:"id"`
+	UserID            int64             `json:"user_id"`
+	TrackID           int64             `json:"track_id"`
+	ListenTime        int               `json:"listen_time"`
+	Timestamp         time.Time         `json:"timestamp"`
+	ScrobbleRules     map[string]string `json:"scrobble_rules"` // Rule that allowed or blocked the listen, by scrobbler
+	Match             TrackMatch        `json:"match"`          // How the track was found in the library
+	Player            string            `json:"player"`         // MPRIS name of the player
+	StartTime         time.Time         `json:"start_time"`
+	EndTime           time.Time         `json:"end_time"`
+	SeekCount         int               `json:"seek_count"`
+	IsSkip            bool              `json:"is_skip"`            // Left before the end of the track
+	Metadata          map[string]any    `json:"metadata"`           // The player's MPRIS metadata, to audit matches
+	ScrobbledServices []string          `json:"scrobbled_services"` // Services that accepted the scrobble
+}
+
+// Scrobble represents a queued scrobble in the database
+type Scrobble struct {
+	ID          int64          `json:"id"`
+	Service     string         `json:"service"`
+	Artist      string         `json:"artist"`
+	Track       string         `json:"track"`
+	Album       string         `json:"album"`
+	MBID        string         `json:"mbid"`
+	Duration    int            `json:"duration"`
+	Timestamp   time.Time      `json:"timestamp"`
+	Player      string         `json:"player"`
+	OriginURL   string         `json:"origin_url"`
+	Status      string         `json:"status"`
+	Attempts    int            `json:"attempts"`
+	NextAttempt time.Time      `json:"next_attempt"`
+	Error       sql.NullString `json:"error"`
+	ListenID    int64          `json:"listen_id"` // 0 for scrobbles queued before listens were linked
 }
 
 // Tag represents a tag in the database
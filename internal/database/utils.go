@@ -209,8 +209,23 @@ func (db *DB) GetAlbums(clauses string, args ...any) ([]*Album, error) {
 	return albums, nil
 }
 
-// AddTracks adds multiple new tracks to the database
+// AddTracks adds multiple new tracks to the database. Tracks whose album was
+// not added yet get the album with its name by one of their artists. Tracks
+// without an album name have no album.
 func (db *DB) AddTracks(tracks []*Track) error {
+	// Albums are added in their own transactions, so they are found first
+	for _, track := range tracks {
+		if track.Album.ID != 0 || track.Album.Name == "" {
+			continue
+		}
+
+		album, err := db.albumByName(track.Album.Name, track.Artists)
+		if err != nil {
+			return err
+		}
+		track.Album.ID = album.ID
+	}
+
 	tx, err := db.Begin()
 	if err != nil {
 		return err
@@ -224,32 +239,9 @@ func (db *DB) AddTracks(tracks []*Track) error {
 	}
 	defer stmtTrack.Close()
 
-	stmtAlbum, err := tx.Prepare("INSERT INTO albums (name, release_date, image_uri) VALUES (?, ?, ?)")
-	if err != nil {
-		return err
-	}
-	defer stmtAlbum.Close()
-
 	for _, track := range tracks {
-		// Check if album exists, if not, add it
-		var albumID int64
-		err := db.QueryRow("SELECT album_id FROM albums WHERE name = ?", track.Album.Name).Scan(&albumID)
-		if err == sql.ErrNoRows {
-			result, err := stmtAlbum.Exec(track.Album.Name, track.Album.ReleaseDate, track.Album.ImageURI)
-			if err != nil {
-				return err
-			}
-			albumID, err = result.LastInsertId()
-			if err != nil {
-				return err
-			}
-			track.Album.ID = albumID
-		} else if err != nil {
-			return err
-		}
-
 		// Insert track
-		result, err := stmtTrack.Exec(albumID, track.Name, track.Duration, track.Lyrics, track.IsExplicit, track.FilePath, track.SHA256Sum)
+		result, err := stmtTrack.Exec(nullIfZero(track.Album.ID), track.Name, track.Duration, track.Lyrics, track.IsExplicit, nullIfEmpty(track.FilePath), nullIfEmpty(track.SHA256Sum))
 		if err != nil {
 			return err
 		}
@@ -285,9 +277,9 @@ func (db *DB) UpdateTrack(track *Track, keys []string, updateKey string, updateV
 		"duration":    track.Duration,
 		"lyrics":      track.Lyrics,
 		"is_explicit": track.IsExplicit,
-		"file_path":   track.FilePath,
-		"sha256sum":   track.SHA256Sum,
-		"album_id":    track.Album.ID,
+		"file_path":   nullIfEmpty(track.FilePath),
+		"sha256sum":   nullIfEmpty(track.SHA256Sum),
+		"album_id":    nullIfZero(track.Album.ID),
 	}
 
 	tx, err := db.Begin()
@@ -351,10 +343,10 @@ func (db *DB) UpdateTrack(track *Track, keys []string, updateKey string, updateV
 // GetTracks retrieves multiple tracks from the database
 func (db *DB) GetTracks(clauses string, args ...any) ([]*Track, error) {
 	query := `
-    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, t.file_path, t.sha256sum,
-    a.album_id, a.name, a.release_date, a.image_uri
+    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, COALESCE(t.file_path, ''), COALESCE(t.sha256sum, ''),
+    COALESCE(a.album_id, 0), COALESCE(a.name, ''), a.release_date, a.image_uri
     FROM tracks t
-    JOIN albums a ON t.album_id = a.album_id
+    LEFT JOIN albums a ON t.album_id = a.album_id
   ` + clauses
 	rows, err := db.Query(query, args...)
 	if err != nil {
@@ -527,14 +519,34 @@ func (db *DB) AddListens(listens []*Listen) error {
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.Prepare("INSERT INTO listens (user_id, track_id, listen_time, timestamp) VALUES (?, ?, ?, ?)")
+	stmt, err := tx.Prepare(`
+    INSERT INTO listens (user_id, track_id, listen_time, timestamp, scrobble_rules, match_method, match_confidence,
+    player, start_time, end_time, seek_count, is_skip, metadata, scrobbled_services)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	for _, listen := range listens {
-		result, err := stmt.Exec(listen.UserID, listen.TrackID, listen.ListenTime, listen.Timestamp)
+		scrobbleRulesJSON, err := json.Marshal(listen.ScrobbleRules)
+		if err != nil {
+			return err
+		}
+
+		metadataJSON, err := json.Marshal(listen.Metadata)
+		if err != nil {
+			return err
+		}
+
+		scrobbledServicesJSON, err := json.Marshal(listen.ScrobbledServices)
+		if err != nil {
+			return err
+		}
+
+		result, err := stmt.Exec(listen.UserID, listen.TrackID, listen.ListenTime, listen.Timestamp, scrobbleRulesJSON, listen.Match.Method, listen.Match.Confidence,
+			listen.Player, listen.StartTime, listen.EndTime, listen.SeekCount, listen.IsSkip, metadataJSON, scrobbledServicesJSON)
 		if err != nil {
 			return err
 		}
@@ -550,10 +562,41 @@ func (db *DB) AddListens(listens []*Listen) error {
 // UpdateListen updates a listen event in the database
 func (db *DB) UpdateListen(listen *Listen, keys []string, updateKey string, updateValue any) error {
 	keyMap := map[string]interface{}{
-		"user_id":     listen.UserID,
-		"track_id":    listen.TrackID,
-		"listen_time": listen.ListenTime,
-		"timestamp":   listen.Timestamp,
+		"user_id":          listen.UserID,
+		"track_id":         listen.TrackID,
+		"listen_time":      listen.ListenTime,
+		"timestamp":        listen.Timestamp,
+		"match_method":     listen.Match.Method,
+		"match_confidence": listen.Match.Confidence,
+		"player":           listen.Player,
+		"start_time":       listen.StartTime,
+		"end_time":         listen.EndTime,
+		"seek_count":       listen.SeekCount,
+		"is_skip":          listen.IsSkip,
+	}
+
+	if contains(keys, "scrobble_rules") {
+		scrobbleRulesJSON, err := json.Marshal(listen.ScrobbleRules)
+		if err != nil {
+			return err
+		}
+		keyMap["scrobble_rules"] = scrobbleRulesJSON
+	}
+
+	if contains(keys, "metadata") {
+		metadataJSON, err := json.Marshal(listen.Metadata)
+		if err != nil {
+			return err
+		}
+		keyMap["metadata"] = metadataJSON
+	}
+
+	if contains(keys, "scrobbled_services") {
+		scrobbledServicesJSON, err := json.Marshal(listen.ScrobbledServices)
+		if err != nil {
+			return err
+		}
+		keyMap["scrobbled_services"] = scrobbledServicesJSON
 	}
 
 	var queryBuilder strings.Builder
@@ -576,9 +619,13 @@ func (db *DB) UpdateListen(listen *Listen, keys []string, updateKey string, upda
 	return err
 }
 
-// GetUserListens retrieves all listen events for a user from the database.
+// GetListens retrieves multiple listen events from the database
 func (db *DB) GetListens(clauses string, args ...any) ([]*Listen, error) {
-	query := "SELECT listen_id, user_id, track_id, listen_time, timestamp FROM listens" + clauses
+	query := `
+    SELECT listen_id, user_id, track_id, listen_time, timestamp, scrobble_rules, match_method, match_confidence,
+    COALESCE(player, ''), start_time, end_time, seek_count, is_skip, metadata, scrobbled_services
+    FROM listens
+  ` + clauses
 	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
@@ -588,16 +635,169 @@ func (db *DB) GetListens(clauses string, args ...any) ([]*Listen, error) {
 	var listens []*Listen
 	for rows.Next() {
 		var listen Listen
-		err := rows.Scan(&listen.ID, &listen.UserID, &listen.TrackID, &listen.ListenTime, &listen.Timestamp)
+		var scrobbleRulesJSON []byte
+		var matchMethod sql.NullString
+		var matchConfidence sql.NullFloat64
+		var startTime, endTime sql.NullTime
+		var metadataJSON, scrobbledServicesJSON []byte
+		err := rows.Scan(&listen.ID, &listen.UserID, &listen.TrackID, &listen.ListenTime, &listen.Timestamp, &scrobbleRulesJSON, &matchMethod, &matchConfidence,
+			&listen.Player, &startTime, &endTime, &listen.SeekCount, &listen.IsSkip, &metadataJSON, &scrobbledServicesJSON)
 		if err != nil {
 			return nil, err
 		}
+
+		// Listens recorded before their context was tracked have none
+		listen.StartTime, listen.EndTime = startTime.Time, endTime.Time
+		if len(metadataJSON) > 0 {
+			if err = json.Unmarshal(metadataJSON, &listen.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if len(scrobbledServicesJSON) > 0 {
+			if err = json.Unmarshal(scrobbledServicesJSON, &listen.ScrobbledServices); err != nil {
+				return nil, err
+			}
+		}
+
+		// Listens recorded before track matches were tracked have none
+		listen.Match = TrackMatch{Method: matchMethod.String, Confidence: matchConfidence.Float64}
+
+		// Listens recorded before scrobble rules were tracked have none
+		if len(scrobbleRulesJSON) > 0 {
+			if err = json.Unmarshal(scrobbleRulesJSON, &listen.ScrobbleRules); err != nil {
+				return nil, err
+			}
+		}
+
 		listens = append(listens, &listen)
 	}
 
 	return listens, nil
 }
 
+// AddScrobbles queues multiple new scrobbles, ignoring ones already queued
+func (db *DB) AddScrobbles(scrobbles []*Scrobble) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("INSERT OR IGNORE INTO scrobbles (service, artist, track, album, mbid, duration, timestamp, player, origin_url, status, attempts, next_attempt, error, listen_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, scrobble := range scrobbles {
+		result, err := stmt.Exec(scrobble.Service, scrobble.Artist, scrobble.Track, scrobble.Album, scrobble.MBID, scrobble.Duration,
+			scrobble.Timestamp, scrobble.Player, scrobble.OriginURL, scrobble.Status, scrobble.Attempts, scrobble.NextAttempt, scrobble.Error, nullIfZero(scrobble.ListenID))
+		if err != nil {
+			return err
+		}
+
+		// Duplicates are ignored and keep an ID of 0
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			continue
+		}
+		scrobble.ID, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateScrobble updates a queued scrobble in the database
+func (db *DB) UpdateScrobble(scrobble *Scrobble, keys []string, updateKey string, updateValue any) error {
+	keyMap := map[string]interface{}{
+		"status":       scrobble.Status,
+		"attempts":     scrobble.Attempts,
+		"next_attempt": scrobble.NextAttempt,
+		"error":        scrobble.Error,
+	}
+
+	var queryBuilder strings.Builder
+	queryBuilder.WriteString("UPDATE scrobbles SET ")
+	args := []interface{}{}
+	for i, key := range keys {
+		if val, ok := keyMap[key]; ok {
+			if i > 0 {
+				queryBuilder.WriteString(", ")
+			}
+			queryBuilder.WriteString(key + " = ?")
+			args = append(args, val)
+		}
+	}
+
+	queryBuilder.WriteString(" WHERE " + updateKey + " = ?")
+	args = append(args, updateValue)
+
+	_, err := db.Exec(queryBuilder.String(), args...)
+	return err
+}
+
+// GetScrobbles retrieves multiple queued scrobbles from the database
+func (db *DB) GetScrobbles(clauses string, args ...any) ([]*Scrobble, error) {
+	query := `
+    SELECT scrobble_id, service, artist, track, album, mbid, duration, timestamp,
+    COALESCE(player, ''), COALESCE(origin_url, ''), status, attempts, next_attempt, error, COALESCE(listen_id, 0)
+    FROM scrobbles
+  ` + clauses
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scrobbles []*Scrobble
+	for rows.Next() {
+		var scrobble Scrobble
+		err := rows.Scan(&scrobble.ID, &scrobble.Service, &scrobble.Artist, &scrobble.Track, &scrobble.Album, &scrobble.MBID, &scrobble.Duration,
+			&scrobble.Timestamp, &scrobble.Player, &scrobble.OriginURL, &scrobble.Status, &scrobble.Attempts, &scrobble.NextAttempt, &scrobble.Error, &scrobble.ListenID)
+		if err != nil {
+			return nil, err
+		}
+		scrobbles = append(scrobbles, &scrobble)
+	}
+
+	return scrobbles, nil
+}
+
+// MarkListenScrobbled records that a service accepted the scrobble of a listen
+func (db *DB) MarkListenScrobbled(listenID int64, service string) error {
+	listens, err := db.GetListens("WHERE listen_id = ?", listenID)
+	if err != nil || len(listens) == 0 {
+		return err
+	}
+
+	listen := listens[0]
+	if contains(listen.ScrobbledServices, service) {
+		return nil
+	}
+	listen.ScrobbledServices = append(listen.ScrobbledServices, service)
+	return db.UpdateListen(listen, []string{"scrobbled_services"}, "listen_id", listenID)
+}
+
+// RemoveScrobbles removes multiple scrobbles from the queue
+func (db *DB) RemoveScrobbles(scrobbleIDs []int64) error {
+	if len(scrobbleIDs) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(scrobbleIDs))
+	args := make([]interface{}, len(scrobbleIDs))
+	for i, scrobbleID := range scrobbleIDs {
+		placeholders[i] = "?"
+		args[i] = scrobbleID
+	}
+
+	query := fmt.Sprintf("DELETE FROM scrobbles WHERE scrobble_id IN (%s)", strings.Join(placeholders, ","))
+	_, err := db.Exec(query, args...)
+	return err
+}
+
 // AddTags adds multiple new tags to the database
 func (db *DB) AddTags(tags []*Tag) error {
 	tx, err := db.Begin()
@@ -821,11 +1021,11 @@ func (db *DB) GetPlaylists(clauses string, args ...any) ([]*Playlist, error) {
 		}
 
 		trackRows, err := db.Query(`
-      SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, t.file_path, t.sha256sum,
-        a.album_id, a.name, a.release_date, a.image_uri
+      SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, COALESCE(t.file_path, ''), COALESCE(t.sha256sum, ''),
+        COALESCE(a.album_id, 0), COALESCE(a.name, ''), a.release_date, a.image_uri
       FROM tracks t
       JOIN playlist_tracks pt ON t.track_id = pt.track_id
-      JOIN albums a ON t.album_id = a.album_id
+      LEFT JOIN albums a ON t.album_id = a.album_id
       WHERE pt.playlist_id = ?
     `, playlist.ID)
 		if err != nil {
@@ -884,10 +1084,10 @@ func (db *DB) GetPlaylists(clauses string, args ...any) ([]*Playlist, error) {
 // SearchTracks searches for tracks based on a query string
 func (db *DB) SearchTracks(query string) ([]*Track, error) {
 	rows, err := db.Query(`
-    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, t.file_path, t.sha256sum,
-      a.album_id, a.name, a.release_date, a.image_uri
+    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, COALESCE(t.file_path, ''), COALESCE(t.sha256sum, ''),
+      COALESCE(a.album_id, 0), COALESCE(a.name, ''), a.release_date, a.image_uri
     FROM tracks t
-    JOIN albums a ON t.album_id = a.album_id
+    LEFT JOIN albums a ON t.album_id = a.album_id
     WHERE t.name LIKE ? OR t.lyrics LIKE ?
   `, "%"+query+"%", "%"+query+"%")
 	if err != nil {
@@ -914,11 +1114,11 @@ func (db *DB) SearchTracks(query string) ([]*Track, error) {
 // GetTopTracks returns the top N most listened tracks
 func (db *DB) GetTopTracks(limit int) ([]*Track, error) {
 	rows, err := db.Query(`
-    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, t.file_path, t.sha256sum,
-      a.album_id, a.name, a.release_date, a.image_uri,
+    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, COALESCE(t.file_path, ''), COALESCE(t.sha256sum, ''),
+      COALESCE(a.album_id, 0), COALESCE(a.name, ''), a.release_date, a.image_uri,
       COUNT(*) as listen_count
     FROM tracks t
-    JOIN albums a ON t.album_id = a.album_id
+    LEFT JOIN albums a ON t.album_id = a.album_id
     JOIN listens l ON t.track_id = l.track_id
     GROUP BY t.track_id
     ORDER BY listen_count DESC
@@ -950,10 +1150,10 @@ func (db *DB) GetTopTracks(limit int) ([]*Track, error) {
 // GetRecentlyAddedTracks returns the N most recently added tracks
 func (db *DB) GetRecentlyAddedTracks(limit int) ([]*Track, error) {
 	rows, err := db.Query(`
-    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, t.file_path, t.sha256sum,
-      a.album_id, a.name, a.release_date, a.image_uri
+    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, COALESCE(t.file_path, ''), COALESCE(t.sha256sum, ''),
+      COALESCE(a.album_id, 0), COALESCE(a.name, ''), a.release_date, a.image_uri
     FROM tracks t
-    JOIN albums a ON t.album_id = a.album_id
+    LEFT JOIN albums a ON t.album_id = a.album_id
     ORDER BY t.track_id DESC
     LIMIT ?
   `, limit)
@@ -1002,10 +1202,10 @@ func (db *DB) GetUserFavoritePlaylists(userID int64) ([]*Playlist, error) {
 // GetTracksByTag returns tracks associated with a specific tag
 func (db *DB) GetTracksByTag(tagID int64) ([]*Track, error) {
 	rows, err := db.Query(`
-    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, t.file_path, t.sha256sum,
-      a.album_id, a.name, a.release_date, a.image_uri
+    SELECT t.track_id, t.name, t.duration, t.lyrics, t.is_explicit, COALESCE(t.file_path, ''), COALESCE(t.sha256sum, ''),
+      COALESCE(a.album_id, 0), COALESCE(a.name, ''), a.release_date, a.image_uri
     FROM tracks t
-    JOIN albums a ON t.album_id = a.album_id
+    LEFT JOIN albums a ON t.album_id = a.album_id
     JOIN track_tags tt ON t.track_id = tt.track_id
     WHERE tt.tag_id = ?
   `, tagID)
@@ -1078,3 +1278,13 @@ func contains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// Store empty strings as NULL, e.g. for the file of remote tracks
+func nullIfEmpty(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// Store zero IDs as NULL, e.g. for tracks without an album or scrobbles without a listen
+func nullIfZero(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
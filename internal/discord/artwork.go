@@ -0,0 +1,113 @@
+package discord
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+	"gitlab.com/AlexJarrah/media-manager/internal/providers/musicbrainz"
+)
+
+// Returns a URL of the track's album art Discord can show: the library's
+// cached art, the Cover Art Archive's front cover, or the player's own
+// mpris:artUrl. Empty when none is available over http(s).
+func AlbumArt(player *players.Player) string {
+	if player.Album == "" {
+		return webURL(player.ArtURL)
+	}
+
+	album := libraryAlbum(player)
+	if album != nil && webURL(album.ImageURI.String) != "" {
+		return album.ImageURI.String
+	}
+
+	art := coverArt(player)
+	if art == "" {
+		return webURL(player.ArtURL)
+	}
+
+	// Only fill in missing art, local images from the library are kept
+	if album != nil && album.ImageURI.String == "" {
+		cacheAlbumArt(album, art)
+	}
+	return art
+}
+
+// Returns the library album matching the player's album & artists, if any
+func libraryAlbum(player *players.Player) *database.Album {
+	db, err := database.NewDB()
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	defer db.Close()
+
+	albums, err := db.GetAlbums("WHERE name = ? COLLATE NOCASE", player.Album)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	for _, album := range albums {
+		if hasArtist(album.Artists, player.Artists) {
+			return album
+		}
+	}
+	return nil
+}
+
+// Library entries without artists, or players without artists, match any artist
+func hasArtist(library []database.Artist, artists []string) bool {
+	if len(library) == 0 || len(artists) == 0 {
+		return true
+	}
+
+	for _, a := range library {
+		for _, artist := range artists {
+			if strings.EqualFold(a.Name, artist) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Look up the album's front cover on the Cover Art Archive
+func coverArt(player *players.Player) string {
+	release, err := musicbrainz.FetchReleaseMBID(*player)
+	if err != nil {
+		log.Println("Failed to find the MusicBrainz release of", player.Album+":", err)
+		return ""
+	}
+
+	art, err := musicbrainz.FetchCoverArt(release)
+	if err != nil {
+		log.Println("Failed to fetch cover art of", player.Album+":", err)
+		return ""
+	}
+	return art
+}
+
+func cacheAlbumArt(album *database.Album, art string) {
+	db, err := database.NewDB()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer db.Close()
+
+	album.ImageURI = sql.NullString{String: art, Valid: true}
+	if err = db.UpdateAlbum(album, []string{"image_uri"}, "album_id", album.ID); err != nil {
+		log.Println(err)
+	}
+}
+
+// Returns the URL if Discord can fetch it, e.g. not a file:// URL
+func webURL(url string) string {
+	if strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
+		return url
+	}
+	return ""
+}
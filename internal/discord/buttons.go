@@ -0,0 +1,65 @@
+package discord
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hugolgst/rich-go/client"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Services that presence buttons can link to
+const (
+	ButtonMusicBrainz = "musicbrainz"
+	ButtonLastFM      = "lastfm"
+)
+
+// Discord shows at most this many buttons
+const maxButtons = 2
+
+// Build buttons linking to the track's pages on the configured services
+func trackButtons(services []string, player *players.Player) []*client.Button {
+	if player.Title == "" || len(player.Artists) == 0 {
+		return nil
+	}
+
+	var buttons []*client.Button
+	for _, service := range services {
+		if len(buttons) == maxButtons {
+			logOnce(fmt.Sprintf("Discord shows at most %d buttons", maxButtons))
+			break
+		}
+
+		switch service {
+		case ButtonMusicBrainz:
+			buttons = append(buttons, &client.Button{Label: "MusicBrainz", Url: musicBrainzURL(player)})
+		case ButtonLastFM:
+			buttons = append(buttons, &client.Button{Label: "Last.fm", Url: lastFMURL(player)})
+		default:
+			logOnce("Ignoring unknown Discord button: " + service)
+		}
+	}
+
+	return buttons
+}
+
+// Links to the recording when its MusicBrainz ID is known, or else searches for it
+func musicBrainzURL(player *players.Player) string {
+	if player.MBID != "" {
+		return "https://musicbrainz.org/recording/" + url.PathEscape(player.MBID)
+	}
+
+	query := fmt.Sprintf(`recording:"%s" AND artist:"%s"`, player.Title, player.Artists[0])
+	return "https://musicbrainz.org/search?type=recording&query=" + url.QueryEscape(query)
+}
+
+func lastFMURL(player *players.Player) string {
+	return "https://www.last.fm/music/" + lastFMEscape(player.Artists[0]) + "/_/" + lastFMEscape(player.Title)
+}
+
+// Last.fm URLs use + for spaces
+func lastFMEscape(s string) string {
+	return strings.ReplaceAll(url.PathEscape(s), "%20", "+")
+}
@@ -0,0 +1,154 @@
+package discord
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hugolgst/rich-go/client"
+	"github.com/hugolgst/rich-go/ipc"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// How often to check whether Discord started, quit or restarted
+const reconnectInterval = 15 * time.Second
+
+// The latest requested presence; nil clears it
+var updates = make(chan *players.Player, 1)
+
+// Request the player's track as the presence, or clear it when player is nil.
+// Never blocks; only the latest request is kept.
+func SetPresence(player *players.Player) {
+	select {
+	case <-updates:
+	default:
+	}
+	updates <- player
+}
+
+// Keep the Discord presence in sync with SetPresence; run in its own goroutine.
+// Discord may start & quit at any time, so the connection is opened lazily.
+func Run() {
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	if config.Discord.ClientID == "" {
+		log.Println("Discord presence disabled, no client_id configured")
+		return
+	}
+
+	var c connection
+	var wanted *players.Player
+
+	ticker := time.NewTicker(reconnectInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case wanted = <-updates:
+		case <-ticker.C:
+		}
+		c.sync(wanted)
+	}
+}
+
+// State of the IPC connection to Discord; only used by Run
+type connection struct {
+	connected bool
+	socket    os.FileInfo // Discord's IPC socket when connecting, to notice restarts
+	shown     string      // Key of the shown presence
+	infoTrack string      // Key of the track the cached info belongs to
+	info      TrackInfo
+}
+
+func (c *connection) sync(player *players.Player) {
+	var activity client.Activity
+	var visible bool
+	if player != nil {
+		config, err := filesystem.GetConfigFile()
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		activity, visible = Activity(player, config.Discord, c.trackInfo(player, config.Discord))
+	}
+
+	if !visible {
+		if c.connected {
+			// Disconnecting is the only way to remove the presence
+			c.disconnect()
+			log.Println("Cleared Discord presence")
+		}
+		return
+	}
+
+	if c.connected && !c.socketUnchanged() {
+		log.Println("Lost connection to Discord")
+		c.disconnect()
+	}
+
+	if !c.connected {
+		socket, err := os.Stat(socketPath())
+		if err != nil {
+			// Discord is not running; retried on the next tick
+			return
+		}
+
+		if err = Login(); err != nil {
+			log.Println("Failed to connect to Discord:", err)
+			return
+		}
+		c.connected, c.socket = true, socket
+	}
+
+	// Timestamps are part of the key, so seeks & resumes are shown too
+	key := presenceKey(activity)
+	if key == c.shown {
+		return
+	}
+
+	if err := client.SetActivity(activity); err != nil {
+		log.Println("Failed to update Discord presence:", err)
+		return
+	}
+	c.shown = key
+}
+
+// Tags & art only change with the track, so they are looked up once per track
+func (c *connection) trackInfo(player *players.Player, config internal.Discord) TrackInfo {
+	track := strings.Join([]string{player.Name, player.Title, strings.Join(player.Artists, ","), player.Album}, "\x00")
+	if track != c.infoTrack {
+		c.infoTrack, c.info = track, LookupTrack(player, config)
+	}
+	return c.info
+}
+
+func (c *connection) disconnect() {
+	Logout()
+	c.connected, c.socket, c.shown = false, nil, ""
+}
+
+// Discord recreates its socket when it restarts, leaving our connection dead
+func (c *connection) socketUnchanged() bool {
+	socket, err := os.Stat(socketPath())
+	return err == nil && os.SameFile(socket, c.socket)
+}
+
+func socketPath() string {
+	return filepath.Join(ipc.GetIpcPath(), "discord-ipc-0")
+}
+
+// Identifies what a presence shows, to skip sending unchanged presences
+func presenceKey(activity client.Activity) string {
+	key, _ := json.Marshal(activity)
+	return string(key)
+}
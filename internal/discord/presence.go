@@ -1,24 +1,69 @@
 package discord
 
 import (
-	"strings"
 	"time"
 
 	"github.com/hugolgst/rich-go/client"
 
+	"gitlab.com/AlexJarrah/media-manager/internal"
 	"gitlab.com/AlexJarrah/media-manager/internal/players"
 )
 
-var elapsed = time.Now()
-
-func UpdatePresence(player *players.Player) error {
-	return client.SetActivity(client.Activity{
-		Details:    player.Title,
-		State:      strings.Join(player.Artists, ", "),
-		LargeText:  player.Album,
-		LargeImage: "image",
-		SmallText:  strings.Join(player.Artists, ", "),
-		SmallImage: player.Artists[0],
-		Timestamps: &client.Timestamps{Start: &elapsed},
-	})
+// Details of a track looked up outside the player
+type TrackInfo struct {
+	Tags   []string
+	ArtURL string
+}
+
+// Look up the track's tags, and its album art unless a rule hides or
+// anonymizes the track, which would show no art anyway
+func LookupTrack(player *players.Player, config internal.Discord) TrackInfo {
+	info := TrackInfo{Tags: TrackTags(player)}
+	if presenceAction(config.Rules, player, info.Tags) == ActionShow {
+		info.ArtURL = AlbumArt(player)
+	}
+	return info
+}
+
+// Build the presence showing the player's track from the config's templates
+// & rules. Returns false when a rule hides the track.
+func Activity(player *players.Player, config internal.Discord, info TrackInfo) (client.Activity, bool) {
+	var fields internal.PresenceTemplates
+	var buttons []*client.Button
+
+	data := newPresenceData(player, info)
+	switch presenceAction(config.Rules, player, info.Tags) {
+	case ActionHide:
+		return client.Activity{}, false
+	case ActionAnonymize:
+		fields = renderPresence(config.Anonymous, defaultAnonymous, data)
+	default:
+		fields = renderPresence(config.Presence, defaultPresence, data)
+		buttons = trackButtons(config.Buttons, player)
+	}
+
+	return client.Activity{
+		Details:    fields.Details,
+		State:      fields.State,
+		LargeText:  fields.LargeText,
+		LargeImage: fields.LargeImage,
+		SmallText:  fields.SmallText,
+		SmallImage: fields.SmallImage,
+		Timestamps: timestamps(player),
+		Buttons:    buttons,
+	}, true
+}
+
+// Start & end of the track, so Discord shows its progress. Rounded to
+// seconds, as that is all Discord shows.
+func timestamps(player *players.Player) *client.Timestamps {
+	start := player.PlaybackStart().Truncate(time.Second)
+	timestamps := &client.Timestamps{Start: &start}
+
+	if end := player.PlaybackEnd(); !end.IsZero() {
+		end = end.Truncate(time.Second)
+		timestamps.End = &end
+	}
+
+	return timestamps
 }
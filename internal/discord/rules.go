@@ -0,0 +1,80 @@
+package discord
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Presence rule actions
+const (
+	ActionShow      = "show"
+	ActionHide      = "hide"
+	ActionAnonymize = "anonymize"
+)
+
+// Returns the action of the first rule matching the player & track tags; tracks are shown by default
+func presenceAction(rules []internal.PresenceRule, player *players.Player, tags []string) string {
+	for _, rule := range rules {
+		if !matchesPlayer(rule.Players, player) || !matchesTags(rule.Tags, tags) {
+			continue
+		}
+
+		switch rule.Action {
+		case ActionShow, ActionHide, ActionAnonymize:
+			return rule.Action
+		default:
+			logOnce("Ignoring Discord rule with unknown action: " + rule.Action)
+		}
+	}
+
+	return ActionShow
+}
+
+// Rules name players with or without the MPRIS prefix, e.g. spotify
+func matchesPlayer(names []string, player *players.Player) bool {
+	if len(names) == 0 {
+		return true
+	}
+
+	for _, name := range names {
+		if strings.EqualFold(name, player.Name) || strings.EqualFold(name, player.ShortName()) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesTags(ruleTags, tags []string) bool {
+	if len(ruleTags) == 0 {
+		return true
+	}
+
+	for _, ruleTag := range ruleTags {
+		for _, tag := range tags {
+			if strings.EqualFold(ruleTag, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var (
+	loggedMu sync.Mutex
+	logged   = make(map[string]bool)
+)
+
+// Log a config problem once, instead of on every presence update
+func logOnce(message string) {
+	loggedMu.Lock()
+	defer loggedMu.Unlock()
+
+	if !logged[message] {
+		logged[message] = true
+		log.Println(message)
+	}
+}
@@ -0,0 +1,52 @@
+package discord
+
+import (
+	"log"
+	"slices"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Returns the track's genres from the player, along with the tags of the
+// matching library track, its album & its artists
+func TrackTags(player *players.Player) []string {
+	tags := slices.Clone(player.Genres)
+
+	db, err := database.NewDB()
+	if err != nil {
+		log.Println(err)
+		return tags
+	}
+	defer db.Close()
+
+	track, _, err := db.MatchTrack(player.TrackQuery())
+	if err != nil {
+		log.Println(err)
+		return tags
+	}
+	if track == nil {
+		return tags
+	}
+
+	trackTags, err := db.GetTags(`
+    WHERE tag_id IN (SELECT tag_id FROM track_tags WHERE track_id = ?)
+    OR tag_id IN (SELECT tag_id FROM album_tags WHERE album_id = ?)
+    OR tag_id IN (
+      SELECT at.tag_id FROM artist_tags at
+      JOIN track_artists ta ON at.artist_id = ta.artist_id
+      WHERE ta.track_id = ?
+    )
+  `, track.ID, track.Album.ID, track.ID)
+	if err != nil {
+		log.Println(err)
+		return tags
+	}
+
+	for _, tag := range trackTags {
+		tags = append(tags, tag.Name)
+	}
+
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
@@ -0,0 +1,124 @@
+package discord
+
+import (
+	"strings"
+	"text/template"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Presence shown when no template is configured
+var defaultPresence = internal.PresenceTemplates{
+	Details:    "{{.Title}}",
+	State:      "{{.Artist}}",
+	LargeText:  "{{.Album}}",
+	LargeImage: `{{or .ArtURL "image"}}`,
+}
+
+// Presence shown for anonymized tracks when no template is configured
+var defaultAnonymous = internal.PresenceTemplates{
+	Details:    "Listening to something",
+	LargeImage: "image",
+}
+
+// Functions available in presence templates
+var templateFuncs = template.FuncMap{
+	"first": func(values []string) string {
+		if len(values) == 0 {
+			return ""
+		}
+		return values[0]
+	},
+	"join": strings.Join,
+}
+
+// Values available in presence templates, e.g. {{.Title}}
+type presenceData struct {
+	Title   string
+	Artist  string   // All artists, comma separated
+	Artists []string // Use {{first .Artists}} for the main artist
+	Album   string
+	Player  string // Short player name, e.g. spotify
+	Tags    []string
+	ArtURL  string // Album art URL, empty when there is none
+}
+
+func newPresenceData(player *players.Player, info TrackInfo) presenceData {
+	return presenceData{
+		Title:   player.Title,
+		Artist:  strings.Join(player.Artists, ", "),
+		Artists: player.Artists,
+		Album:   player.Album,
+		Player:  player.ShortName(),
+		Tags:    info.Tags,
+		ArtURL:  info.ArtURL,
+	}
+}
+
+// Fill in the configured templates, using the defaults for empty fields
+func renderPresence(templates, defaults internal.PresenceTemplates, data presenceData) internal.PresenceTemplates {
+	return internal.PresenceTemplates{
+		Details:    truncate(render("details", templates.Details, defaults.Details, data), maxFieldLength),
+		State:      truncate(render("state", templates.State, defaults.State, data), maxFieldLength),
+		LargeText:  truncate(render("large_text", templates.LargeText, defaults.LargeText, data), maxFieldLength),
+		LargeImage: checkImage("large_image", render("large_image", templates.LargeImage, defaults.LargeImage, data)),
+		SmallText:  truncate(render("small_text", templates.SmallText, defaults.SmallText, data), maxFieldLength),
+		SmallImage: checkImage("small_image", render("small_image", templates.SmallImage, defaults.SmallImage, data)),
+	}
+}
+
+// Render a template, falling back to the default when it is empty or broken
+func render(name, text, fallback string, data presenceData) string {
+	if text == "" {
+		text = fallback
+	}
+
+	result, err := execute(name, text, data)
+	if err != nil && text != fallback {
+		logOnce("Invalid Discord " + name + " template: " + err.Error())
+		result, err = execute(name, fallback, data)
+	}
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(result)
+}
+
+// Discord rejects text fields longer than this
+const maxFieldLength = 128
+
+// Discord rejects image keys & URLs longer than this
+const maxImageLength = 256
+
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// Image keys & URLs are dropped rather than truncated, as a cut one is broken
+func checkImage(name, image string) string {
+	if len([]rune(image)) <= maxImageLength {
+		return image
+	}
+
+	logOnce("Discord " + name + " is too long, leaving it out: " + image)
+	return ""
+}
+
+func execute(name, text string, data presenceData) (string, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if err = t.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
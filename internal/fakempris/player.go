@@ -0,0 +1,334 @@
+package fakempris
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
+)
+
+// Fake MPRIS player, following a script instead of playing audio
+type Player struct {
+	Name string // Well-known bus name, e.g. org.mpris.MediaPlayer2.fake
+
+	mu       sync.Mutex
+	conn     *dbus.Conn
+	speed    float64
+	status   string
+	metadata map[string]dbus.Variant
+	rate     float64
+	position time.Duration // Position at updated
+	updated  time.Time
+	tracks   int
+}
+
+// Register a fake player as org.mpris.MediaPlayer2.<name>. With a speed
+// above 1, scripts run faster & the player reports a matching playback
+// rate, so listeners still count the scripted play time.
+func NewPlayer(conn *dbus.Conn, name string, speed float64) (*Player, error) {
+	if speed <= 0 {
+		speed = 1
+	}
+
+	p := &Player{
+		Name:     bus.MPRISPrefix + name,
+		conn:     conn,
+		speed:    speed,
+		status:   "Stopped",
+		metadata: map[string]dbus.Variant{},
+		rate:     1,
+		updated:  time.Now(),
+	}
+
+	if err := conn.Export(mediaPlayer{p}, bus.MPRISPath, "org.mpris.MediaPlayer2"); err != nil {
+		return nil, err
+	}
+	// Seek is renamed, so it is not mistaken for io.Seeker
+	if err := conn.ExportWithMap(playerControls{p}, map[string]string{"SeekBy": "Seek"}, bus.MPRISPath, bus.PlayerIface); err != nil {
+		return nil, err
+	}
+	if err := conn.Export(properties{p}, bus.MPRISPath, bus.PropertiesIface); err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.RequestName(p.Name, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("bus name %s is already taken", p.Name)
+	}
+
+	return p, nil
+}
+
+// Play a script, releasing the bus name at its end
+func (p *Player) Run(steps []Step) error {
+	defer p.Quit()
+
+	for _, step := range steps {
+		switch step.Action {
+		case ActionTrack:
+			p.LoadTrack(step)
+		case ActionPlay:
+			p.SetStatus("Playing")
+		case ActionPause:
+			p.SetStatus("Paused")
+		case ActionStop:
+			p.SetStatus("Stopped")
+		case ActionSeek:
+			p.Seek(seconds(step.Position))
+		case ActionRate:
+			p.SetRate(step.Rate)
+		case ActionWait:
+			time.Sleep(time.Duration(float64(seconds(step.Seconds)) / p.speed))
+		case ActionQuit:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Release the player's bus name, as when the player quits
+func (p *Player) Quit() error {
+	_, err := p.conn.ReleaseName(p.Name)
+	return err
+}
+
+// Load a track & rewind to its start
+func (p *Player) LoadTrack(step Step) {
+	p.mu.Lock()
+	p.tracks++
+	trackID := step.TrackID
+	if trackID == "" {
+		trackID = fmt.Sprintf("/org/mpris/MediaPlayer2/Track/%d", p.tracks)
+	}
+
+	p.metadata = map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(trackID)),
+		"xesam:title":   dbus.MakeVariant(step.Title),
+		"xesam:artist":  dbus.MakeVariant(step.Artists),
+		"xesam:album":   dbus.MakeVariant(step.Album),
+	}
+	if step.Length > 0 {
+		p.metadata["mpris:length"] = dbus.MakeVariant(seconds(step.Length).Microseconds())
+	}
+	if len(step.Genres) > 0 {
+		p.metadata["xesam:genre"] = dbus.MakeVariant(step.Genres)
+	}
+	if step.URL != "" {
+		p.metadata["xesam:url"] = dbus.MakeVariant(step.URL)
+	}
+
+	p.position = 0
+	p.updated = time.Now()
+	metadata := p.metadata
+	p.mu.Unlock()
+
+	p.emitChanged(map[string]dbus.Variant{"Metadata": dbus.MakeVariant(metadata)})
+}
+
+func (p *Player) SetStatus(status string) {
+	p.mu.Lock()
+	p.checkpoint()
+	p.status = status
+	if status == "Stopped" {
+		p.position = 0
+	}
+	p.mu.Unlock()
+
+	p.emitChanged(map[string]dbus.Variant{"PlaybackStatus": dbus.MakeVariant(status)})
+}
+
+// Jump to a position & announce it with Seeked
+func (p *Player) Seek(position time.Duration) {
+	p.mu.Lock()
+	p.checkpoint()
+	p.position = max(position, 0)
+	position = p.position
+	p.mu.Unlock()
+
+	err := p.conn.Emit(bus.MPRISPath, bus.PlayerIface+".Seeked", position.Microseconds())
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func (p *Player) SetRate(rate float64) {
+	p.mu.Lock()
+	p.checkpoint()
+	p.rate = rate
+	p.mu.Unlock()
+
+	p.emitChanged(map[string]dbus.Variant{"Rate": dbus.MakeVariant(rate * p.speed)})
+}
+
+// Advance the position by the time played since the last update; requires p.mu
+func (p *Player) checkpoint() {
+	p.position = p.currentPosition()
+	p.updated = time.Now()
+}
+
+// Requires p.mu
+func (p *Player) currentPosition() time.Duration {
+	if p.status != "Playing" {
+		return p.position
+	}
+	return p.position + time.Duration(float64(time.Since(p.updated))*p.rate*p.speed)
+}
+
+// Requires p.mu
+func (p *Player) properties() map[string]dbus.Variant {
+	return map[string]dbus.Variant{
+		"PlaybackStatus": dbus.MakeVariant(p.status),
+		"Metadata":       dbus.MakeVariant(p.metadata),
+		"Position":       dbus.MakeVariant(p.currentPosition().Microseconds()),
+		"Rate":           dbus.MakeVariant(p.rate * p.speed),
+		"MinimumRate":    dbus.MakeVariant(0.25 * p.speed),
+		"MaximumRate":    dbus.MakeVariant(4 * p.speed),
+		"Volume":         dbus.MakeVariant(1.0),
+		"CanGoNext":      dbus.MakeVariant(false),
+		"CanGoPrevious":  dbus.MakeVariant(false),
+		"CanPlay":        dbus.MakeVariant(true),
+		"CanPause":       dbus.MakeVariant(true),
+		"CanSeek":        dbus.MakeVariant(true),
+		"CanControl":     dbus.MakeVariant(true),
+	}
+}
+
+func (p *Player) emitChanged(changed map[string]dbus.Variant) {
+	err := p.conn.Emit(bus.MPRISPath, bus.PropertiesIface+".PropertiesChanged", bus.PlayerIface, changed, []string{})
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// The org.mpris.MediaPlayer2 interface
+type mediaPlayer struct {
+	p *Player
+}
+
+func (m mediaPlayer) Raise() *dbus.Error {
+	return nil
+}
+
+func (m mediaPlayer) Quit() *dbus.Error {
+	if err := m.p.Quit(); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// The org.mpris.MediaPlayer2.Player interface, for controlling the player from other tools
+type playerControls struct {
+	p *Player
+}
+
+func (c playerControls) Play() *dbus.Error {
+	c.p.SetStatus("Playing")
+	return nil
+}
+
+func (c playerControls) Pause() *dbus.Error {
+	c.p.SetStatus("Paused")
+	return nil
+}
+
+func (c playerControls) PlayPause() *dbus.Error {
+	c.p.mu.Lock()
+	playing := c.p.status == "Playing"
+	c.p.mu.Unlock()
+
+	if playing {
+		return c.Pause()
+	}
+	return c.Play()
+}
+
+func (c playerControls) Stop() *dbus.Error {
+	c.p.SetStatus("Stopped")
+	return nil
+}
+
+func (c playerControls) Next() *dbus.Error {
+	return nil
+}
+
+func (c playerControls) Previous() *dbus.Error {
+	return nil
+}
+
+// Seek by an offset in microseconds
+func (c playerControls) SeekBy(offset int64) *dbus.Error {
+	c.p.mu.Lock()
+	position := c.p.currentPosition()
+	c.p.mu.Unlock()
+
+	c.p.Seek(position + time.Duration(offset)*time.Microsecond)
+	return nil
+}
+
+func (c playerControls) SetPosition(trackID dbus.ObjectPath, position int64) *dbus.Error {
+	c.p.Seek(time.Duration(position) * time.Microsecond)
+	return nil
+}
+
+func (c playerControls) OpenUri(uri string) *dbus.Error {
+	return nil
+}
+
+// The org.freedesktop.DBus.Properties interface
+type properties struct {
+	p *Player
+}
+
+func (pr properties) Get(iface, property string) (dbus.Variant, *dbus.Error) {
+	all, err := pr.GetAll(iface)
+	if err != nil {
+		return dbus.Variant{}, err
+	}
+
+	v, ok := all[property]
+	if !ok {
+		return dbus.Variant{}, dbus.NewError("org.freedesktop.DBus.Error.UnknownProperty", []interface{}{property})
+	}
+	return v, nil
+}
+
+func (pr properties) GetAll(iface string) (map[string]dbus.Variant, *dbus.Error) {
+	switch iface {
+	case bus.PlayerIface:
+		pr.p.mu.Lock()
+		defer pr.p.mu.Unlock()
+		return pr.p.properties(), nil
+	case "org.mpris.MediaPlayer2":
+		return map[string]dbus.Variant{
+			"Identity":            dbus.MakeVariant("Fake MPRIS player"),
+			"CanQuit":             dbus.MakeVariant(true),
+			"CanRaise":            dbus.MakeVariant(false),
+			"HasTrackList":        dbus.MakeVariant(false),
+			"SupportedUriSchemes": dbus.MakeVariant([]string{}),
+			"SupportedMimeTypes":  dbus.MakeVariant([]string{}),
+		}, nil
+	}
+	return nil, dbus.NewError("org.freedesktop.DBus.Error.UnknownInterface", []interface{}{iface})
+}
+
+func (pr properties) Set(iface, property string, v dbus.Variant) *dbus.Error {
+	if iface == bus.PlayerIface && property == "Rate" {
+		if rate, ok := v.Value().(float64); ok {
+			pr.p.SetRate(rate / pr.p.speed)
+			return nil
+		}
+	}
+	return dbus.NewError("org.freedesktop.DBus.Error.PropertyReadOnly", []interface{}{property})
+}
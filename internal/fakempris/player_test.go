@@ -0,0 +1,90 @@
+package fakempris
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
+)
+
+func TestReadScript(t *testing.T) {
+	script := `
+# Comments & blank lines are skipped
+{"action": "track", "title": "Song", "artists": ["Artist"], "length": 200}
+
+{"action": "wait", "seconds": 30}
+`
+	steps, err := ReadScript(strings.NewReader(script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 2 || steps[0].Title != "Song" || steps[1].Seconds != 30 {
+		t.Errorf("got steps %+v", steps)
+	}
+
+	_, err = ReadScript(strings.NewReader("{\"action\": \"play\"}\n{\"action\": \"rewind\"}"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v, want one for line 2", err)
+	}
+}
+
+// The player's properties as another bus client sees them
+func TestPlayer(t *testing.T) {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+
+	session, err := StartSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+
+	playerConn, err := session.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { playerConn.Close() })
+
+	clientConn, err := session.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { clientConn.Close() })
+	client := bus.Wrap(clientConn)
+
+	p, err := NewPlayer(playerConn, "test", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 0.4s of real time is 20s of playback
+	p.LoadTrack(Step{Title: "Song", Artists: []string{"Artist"}, Length: 200})
+	p.SetStatus("Playing")
+	time.Sleep(400 * time.Millisecond)
+	p.SetStatus("Paused")
+
+	props, err := client.GetAllProperties(p.Name, bus.PlayerIface)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := props["PlaybackStatus"].Value(); status != "Paused" {
+		t.Errorf("got status %v, want Paused", status)
+	}
+	if rate := props["Rate"].Value(); rate != 50.0 {
+		t.Errorf("got rate %v, want 50", rate)
+	}
+	position := time.Duration(props["Position"].Value().(int64)) * time.Microsecond
+	if position < 18*time.Second || position > 25*time.Second {
+		t.Errorf("got position %v, want about 20s", position)
+	}
+
+	if err = p.Run([]Step{{Action: ActionQuit}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = client.GetNameOwner(p.Name); err == nil {
+		t.Error("the player still owns its name after quitting")
+	}
+}
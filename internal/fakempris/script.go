@@ -0,0 +1,64 @@
+package fakempris
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Script actions
+const (
+	ActionTrack = "track" // Load a track & rewind to its start
+	ActionPlay  = "play"
+	ActionPause = "pause"
+	ActionStop  = "stop"
+	ActionSeek  = "seek" // Jump to Position
+	ActionRate  = "rate" // Change the playback rate to Rate
+	ActionWait  = "wait" // Let Seconds of playback pass
+	ActionQuit  = "quit" // Release the player's bus name
+)
+
+// One line of a player script, e.g. {"action": "wait", "seconds": 30}
+type Step struct {
+	Action   string   `json:"action"`
+	Title    string   `json:"title,omitempty"`
+	Artists  []string `json:"artists,omitempty"`
+	Album    string   `json:"album,omitempty"`
+	Genres   []string `json:"genres,omitempty"`
+	Length   float64  `json:"length,omitempty"`   // Track length in seconds
+	TrackID  string   `json:"trackid,omitempty"`  // Defaults to a new ID per track
+	URL      string   `json:"url,omitempty"`      // xesam:url, e.g. file:///music/track.flac
+	Position float64  `json:"position,omitempty"` // Seek target in seconds
+	Rate     float64  `json:"rate,omitempty"`
+	Seconds  float64  `json:"seconds,omitempty"`
+}
+
+// Read a script of JSON steps, one per line. Blank lines & lines starting with # are skipped.
+func ReadScript(r io.Reader) ([]Step, error) {
+	var steps []Step
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var step Step
+		if err := json.Unmarshal([]byte(line), &step); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		switch step.Action {
+		case ActionTrack, ActionPlay, ActionPause, ActionStop, ActionSeek, ActionRate, ActionWait, ActionQuit:
+		default:
+			return nil, fmt.Errorf("line %d: unknown action %q", n, step.Action)
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, scanner.Err()
+}
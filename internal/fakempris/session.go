@@ -0,0 +1,57 @@
+package fakempris
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// A private D-Bus session, so fake players never meet the user's real ones
+type Session struct {
+	Address string
+	cmd     *exec.Cmd
+}
+
+// Start a dbus-daemon for a new session bus
+func StartSession() (*Session, error) {
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start dbus-daemon: %v", err)
+	}
+
+	// The daemon prints its address once it is ready for connections
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, fmt.Errorf("failed to read dbus-daemon address: %v", err)
+	}
+
+	return &Session{Address: strings.TrimSpace(address), cmd: cmd}, nil
+}
+
+// Open a connection to the session bus
+func (s *Session) Connect() (*dbus.Conn, error) {
+	conn, err := dbus.Connect(s.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", s.Address, err)
+	}
+	return conn, nil
+}
+
+// Stop the dbus-daemon
+func (s *Session) Close() error {
+	if err := s.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	s.cmd.Wait()
+	return nil
+}
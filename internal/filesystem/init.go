@@ -32,7 +32,7 @@ func Initialize() error {
 		}
 
 		if _, err = os.Stat(filename); os.IsNotExist(err) {
-			if err = os.WriteFile(filename, data, 0644); err != nil {
+			if err = os.WriteFile(filename, data, 0600); err != nil {
 				return err
 			}
 		}
@@ -77,5 +77,65 @@ func WriteConfigFile(config internal.Config) (err error) {
 		return err
 	}
 
-	return os.WriteFile(filepath.Join(dir, "config.json"), json_, 0677)
+	// The config holds credentials, so it is only readable by the current user.
+	// WriteFile keeps the mode of existing files, so fix up older configs too.
+	path := filepath.Join(dir, "config.json")
+	if err = os.WriteFile(path, json_, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(path, 0600)
+}
+
+// Returns cached service session keys
+func GetSessions() (sessions map[string]string, err error) {
+	dir, err := GetDataDir()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.ReadFile(filepath.Join(dir, "sessions.json"))
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(file, &sessions); err != nil {
+		return nil, err
+	}
+
+	if sessions == nil {
+		sessions = map[string]string{}
+	}
+	return sessions, nil
+}
+
+// Writes cached service session keys, readable only by the current user
+func WriteSessions(sessions map[string]string) (err error) {
+	dir, err := GetDataDir()
+	if err != nil {
+		return err
+	}
+
+	json_, err := json.MarshalIndent(sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Replace the file in one step, so readers never see it half written.
+	// Temporary files are only readable by the current user.
+	file, err := os.CreateTemp(dir, "sessions-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err = file.Write(json_); err != nil {
+		file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), filepath.Join(dir, "sessions.json"))
 }
@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
+	"gitlab.com/AlexJarrah/media-manager/internal/fakempris"
+	"gitlab.com/AlexJarrah/media-manager/internal/scrobbler"
+)
+
+// Runs the script 50 times faster, in a little over 3s
+const integrationScript = `
+{"action": "track", "title": "First", "artists": ["Artist"], "length": 200}
+{"action": "play"}
+{"action": "wait", "seconds": 120}
+{"action": "track", "title": "Second", "artists": ["Artist"], "length": 100}
+{"action": "wait", "seconds": 20}
+{"action": "pause"}
+{"action": "wait", "seconds": 30}
+{"action": "quit"}
+`
+
+// Follow a fake player on a private session bus, as the monitor would follow a real one
+func TestMonitorPlayersIntegration(t *testing.T) {
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon is not installed")
+	}
+	if testing.Short() {
+		t.Skip("runs a fake player in real time")
+	}
+
+	fake := newTestEnv(t)
+
+	session, err := fakempris.StartSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { session.Close() })
+
+	playerConn, err := session.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { playerConn.Close() })
+
+	player, err := fakempris.NewPlayer(playerConn, strings.TrimPrefix(testPlayer, bus.MPRISPrefix), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	monitorConn, err := session.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the connection closes the monitor's signals, which ends it
+	done := make(chan error, 1)
+	go func() { done <- MonitorPlayers(bus.Wrap(monitorConn)) }()
+	stop := sync.OnceValue(func() error {
+		monitorConn.Close()
+		return <-done
+	})
+	t.Cleanup(func() { stop() })
+
+	steps, err := fakempris.ReadScript(strings.NewReader(integrationScript))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = player.Run(steps); err != nil {
+		t.Fatal(err)
+	}
+
+	// The player quit, so the monitor saves its last listen
+	deadline := time.Now().Add(5 * time.Second)
+	for len(testListens(t)) < 2 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err = stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	listens := testListens(t)
+	if len(listens) != 2 {
+		t.Fatalf("got %d listens, want 2", len(listens))
+	}
+
+	// The player reports the speed as its rate, so play time follows the script
+	for i, want := range []int{120, 20} {
+		if got := listens[i].ListenTime; got < want-5 || got > want+5 {
+			t.Errorf("listen %d: got %ds, want about %ds", i, got, want)
+		}
+		if listens[i].Player != testPlayer {
+			t.Errorf("listen %d: got player %q, want %q", i, listens[i].Player, testPlayer)
+		}
+	}
+
+	if err = scrobbler.FlushQueue(); err != nil {
+		t.Fatal(err)
+	}
+	if scrobbles := fake.submitted(); len(scrobbles) != 1 || scrobbles[0].Track != "First" {
+		t.Errorf("got scrobbles %+v, want one of First", scrobbles)
+	}
+}
@@ -3,24 +3,35 @@ package monitor
 import (
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/godbus/dbus/v5"
 
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
 	"gitlab.com/AlexJarrah/media-manager/internal/database"
-	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
 	"gitlab.com/AlexJarrah/media-manager/internal/players"
-	"gitlab.com/AlexJarrah/media-manager/internal/providers/lastfm"
 	"gitlab.com/AlexJarrah/media-manager/internal/providers/musicbrainz"
+	"gitlab.com/AlexJarrah/media-manager/internal/scrobbler"
 )
 
-func MonitorPlayers(conn *dbus.Conn) error {
+// D-Bus signals handled by the monitor
+const (
+	signalPropertiesChanged = "org.freedesktop.DBus.Properties.PropertiesChanged"
+	signalSeeked            = "org.mpris.MediaPlayer2.Player.Seeked"
+	signalNameOwnerChanged  = "org.freedesktop.DBus.NameOwnerChanged"
+)
+
+func MonitorPlayers(conn bus.Bus) error {
 	c := make(chan *dbus.Signal, 10)
 	conn.Signal(c)
 
 	err := conn.AddMatchSignal(
-		dbus.WithMatchObjectPath("/org/mpris/MediaPlayer2"),
+		dbus.WithMatchObjectPath(bus.MPRISPath),
 		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
 		dbus.WithMatchMember("PropertiesChanged"),
 	)
@@ -28,59 +39,357 @@ func MonitorPlayers(conn *dbus.Conn) error {
 		return fmt.Errorf("failed to add match rule: %v", err)
 	}
 
+	err = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(bus.MPRISPath),
+		dbus.WithMatchInterface("org.mpris.MediaPlayer2.Player"),
+		dbus.WithMatchMember("Seeked"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add match rule: %v", err)
+	}
+
+	// Notice players quitting, so their last listen is not lost
+	err = conn.AddMatchSignal(
+		dbus.WithMatchSender("org.freedesktop.DBus"),
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg0Namespace("org.mpris.MediaPlayer2"),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add match rule: %v", err)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	players.LoadSettings()
+
+	// Match rules are in place, so players changing from here on are not missed
+	if recording != nil {
+		recordPlayers(conn)
+	}
+	bootstrapPlayers(conn)
+	updatePresence()
+
+	polls := make(chan polledProperties, 10)
+	startPollers(conn, polls)
+
 	log.Println("Monitoring for track changes...")
-	handleSignals(c, conn)
+	handleSignals(c, polls, stop, conn)
 	return nil
 }
 
-func handleSignals(c <-chan *dbus.Signal, conn *dbus.Conn) {
-	for sig := range c {
-		player, props, ok := validateSignal(sig, conn)
-		if !ok {
+// Pick up the tracks of players that were already running before the monitor started
+func bootstrapPlayers(conn bus.Bus) {
+	names, err := players.GetAllMediaPlayers(conn)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, name := range names {
+		if _, err := players.GetPlayerByName(name); err == nil {
 			continue
 		}
 
-		// Verify new track is playing by comparing track & artist names
-		if variant, ok := props["Metadata"]; ok {
-			metadata := variant.Value().(map[string]dbus.Variant)
-			var newTitle, newArtists bool
+		player := &players.Player{Name: name}
+		if !player.IsWhitelisted() {
+			continue
+		}
+
+		props, err := players.GetPlayerProperties(name, conn)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		players.Players.Add(player)
+		bootstrapPlayer(player, props, conn)
+	}
+}
+
+func bootstrapPlayer(player *players.Player, props map[string]dbus.Variant, conn bus.Bus) {
+	if variant, ok := props["Metadata"]; ok {
+		player.UpdateMediaPlayerMetadata(variant, conn)
+	}
+
+	if rate, ok := props["Rate"]; ok {
+		handleRate(player, rate)
+	}
+
+	// The track was started before we were listening. It started position ago,
+	// but only the play time heard from now on counts.
+	var position time.Duration
+	if v, ok := props["Position"]; ok {
+		microseconds, _ := v.Value().(int64)
+		position = time.Duration(microseconds) * time.Microsecond
+	}
+
+	player.ResetPlayTime()
+	player.Position = position
+	player.StartListeningTime = players.Now().Add(-position)
+
+	if v, ok := props["PlaybackStatus"]; ok {
+		status, _ := v.Value().(string)
+		if err := player.SetPlaybackStatus(status); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if player.Title == "" {
+		return
+	}
 
-			if v, exists := metadata["xesam:title"]; exists {
-				title := v.Value().(string)
-				newTitle = player.Title != title && title != ""
+	log.Printf("Found %s playing %s (%s, %v in)\n", player.ShortName(), player.Title, player.Status, position.Truncate(time.Second))
+	if player.Status == players.StatusPlaying {
+		go scrobbler.NowPlaying(player.Snapshot())
+	}
+}
+
+// Player state is only changed from this loop; background work is handed snapshots
+func handleSignals(c <-chan *dbus.Signal, polls <-chan polledProperties, stop <-chan os.Signal, conn bus.Bus) {
+	for {
+		select {
+		case sig, ok := <-c:
+			if !ok {
+				log.Println("Signal connection closed, shutting down...")
+				finishAllListens()
+				return
 			}
 
-			if v, exists := metadata["xesam:artist"]; exists {
-				artists := v.Value().([]string)
-				newArtists = strings.Join(player.Artists, ",") != strings.Join(artists, ",") && len(artists) != 0
+			if recording != nil {
+				recordSignal(sig)
 			}
 
-			// If track title and/or artists changed, handle as new track
-			if newTitle || newArtists {
-				handleNewTrack(player, variant, conn)
+			switch sig.Name {
+			case signalPropertiesChanged:
+				handlePropertiesChanged(sig, conn)
+			case signalSeeked:
+				handleSeeked(sig, conn)
+			case signalNameOwnerChanged:
+				handleNameOwnerChanged(sig)
+			case signalPolled:
+				handleReplayedPoll(sig, conn)
 			}
+		case polled := <-polls:
+			if recording != nil {
+				recordPolledProperties(polled)
+			}
+			handlePolledProperties(polled, conn)
+		case settled := <-settledTracks:
+			handleSettledTrack(settled, conn)
+		case s := <-stop:
+			log.Printf("Received %v, shutting down...\n", s)
+			finishAllListens()
+			return
+		}
+
+		// Follow any change of what is playing on Discord
+		updatePresence()
+	}
+}
+
+// Keep track of which player owns which bus name. A player quit when its
+// name loses its owner, which ends the track it was playing.
+func handleNameOwnerChanged(sig *dbus.Signal) {
+	if len(sig.Body) < 3 {
+		return
+	}
+
+	name, _ := sig.Body[0].(string)
+	oldOwner, _ := sig.Body[1].(string)
+	newOwner, _ := sig.Body[2].(string)
+	players.Players.SetOwner(name, oldOwner, newOwner)
+	if newOwner != "" {
+		return
+	}
+
+	player, err := players.GetPlayerByName(name)
+	if err != nil {
+		return
+	}
+
+	log.Println("Player quit:", player.Name)
+	players.RemovePlayer(player.Name)
+	dropPendingTrack(player.Name)
+	player.Pause()
+
+	if player.Title != "" {
+		finishListen(player)
+	}
+}
+
+// Listens being recorded in the background
+var pendingListens sync.WaitGroup
+
+// Record the player's listen of its current track in the background
+func finishListen(player *players.Player) {
+	// Stop the copy's clock, so the listen ends now rather than when it is saved
+	listen := player.Snapshot()
+	listen.Pause()
+	end := players.Now()
+
+	pendingListens.Add(1)
+	go func() {
+		defer pendingListens.Done()
+		onTrackChange(listen, end)
+	}()
+}
+
+// Record the in-progress listen of every player, waiting for all listens to be saved
+func finishAllListens() {
+	for _, player := range players.Players.All() {
+		if player.Title == "" {
+			continue
+		}
+
+		player.Pause()
+		finishListen(player)
+	}
+	pendingListens.Wait()
+}
+
+func handlePropertiesChanged(sig *dbus.Signal, conn bus.Bus) {
+	player, props, ok := validateSignal(sig, conn)
+	if !ok {
+		return
+	}
+
+	handleProperties(player, props, conn)
+}
+
+// Apply changed player properties, from a signal or from polling
+func handleProperties(player *players.Player, props map[string]dbus.Variant, conn bus.Bus) {
+	_, pending := pendingTracks[player.Name]
+	position, hasPosition := props["Position"]
+
+	if variant, ok := props["Metadata"]; ok {
+		metadata := variant.Value().(map[string]dbus.Variant)
+		delay := player.DebounceDelay()
+
+		if pending || (delay > 0 && isNewTrack(player, metadata)) {
+			debounceTrack(player, metadata, delay)
+			pending = true
+		} else if isNewTrack(player, metadata) {
+			handleNewTrack(player, variant, conn)
+		} else if !hasPosition && isReplay(player, conn) {
+			handleRestart(player, 0)
+		} else if v, exists := metadata["xesam:userRating"]; exists {
+			handleRating(player, v)
+		}
+	}
+
+	// Apply the rate first, so audio heard before a status change counts at the old rate
+	if rate, ok := props["Rate"]; ok {
+		handleRate(player, rate)
+	}
+
+	if status, ok := props["PlaybackStatus"]; ok {
+		handlePlaybackStatus(player, status, conn)
+	}
+
+	// While the track is changing, the position may belong to either track
+	if hasPosition && !pending {
+		handlePosition(player, position)
+	}
+}
+
+// The player jumped to a new position, so the skipped audio must not count as heard
+func handleSeeked(sig *dbus.Signal, conn bus.Bus) {
+	player, ok := validateSender(sig, conn)
+	if !ok || len(sig.Body) == 0 {
+		return
+	}
+
+	// Position is in microseconds
+	microseconds, ok := sig.Body[0].(int64)
+	if !ok {
+		return
+	}
+	position := time.Duration(microseconds) * time.Microsecond
+
+	if player.Title == "" {
+		player.SyncPosition(position)
+		return
+	}
+
+	// The finished listen keeps the position it was left at
+	if player.IsRestart(position) {
+		handleRestart(player, position)
+		return
+	}
+	player.Seek(position)
+}
+
+// Verify new track is playing by comparing track & artist names, or the
+// track ID & URL, which change when the same track is queued again
+func isNewTrack(player *players.Player, metadata map[string]dbus.Variant) bool {
+	if v, exists := metadata["xesam:title"]; exists {
+		title, _ := v.Value().(string)
+		if player.Title != title && title != "" {
+			return true
 		}
+	}
+
+	if v, exists := metadata["xesam:artist"]; exists {
+		artists, _ := v.Value().([]string)
+		if strings.Join(player.Artists, ",") != strings.Join(artists, ",") && len(artists) != 0 {
+			return true
+		}
+	}
 
-		if status, ok := props["PlaybackStatus"]; ok {
-			handlePlaybackStatus(player, status)
+	if v, exists := metadata["mpris:trackid"]; exists {
+		trackID := players.TrackID(v)
+		if player.TrackID != trackID && player.TrackID != "" && trackID != "" {
+			return true
 		}
 	}
+
+	if v, exists := metadata["xesam:url"]; exists {
+		url, _ := v.Value().(string)
+		if player.URL != url && player.URL != "" && url != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Players looping a track often resend the same metadata instead of
+// announcing a seek, so check whether the position jumped back to the start
+func isReplay(player *players.Player, conn bus.Bus) bool {
+	if player.Title == "" {
+		return false
+	}
+
+	position, err := player.GetTrackPosition(conn)
+	return err == nil && player.IsRestart(position)
 }
 
-func validateSignal(sig *dbus.Signal, conn *dbus.Conn) (*players.Player, map[string]dbus.Variant, bool) {
+func validateSender(sig *dbus.Signal, conn bus.Bus) (*players.Player, bool) {
 	player, err := players.GetPlayerBySignal(sig.Sender, conn)
 	if err != nil {
 		log.Printf("Error getting player name: %v\n", err)
-		return nil, nil, false
+		return nil, false
 	}
 
 	if !player.IsWhitelisted() {
 		log.Println("Ignored player:", player.Name)
+		return nil, false
+	}
+
+	return player, true
+}
+
+func validateSignal(sig *dbus.Signal, conn bus.Bus) (*players.Player, map[string]dbus.Variant, bool) {
+	player, ok := validateSender(sig, conn)
+	if !ok || len(sig.Body) < 2 {
 		return nil, nil, false
 	}
 
 	ifaceName, ok := sig.Body[0].(string)
-	if !ok || ifaceName != "org.mpris.MediaPlayer2.Player" {
+	if !ok || ifaceName != bus.PlayerIface {
 		return nil, nil, false
 	}
 
@@ -92,28 +401,86 @@ func validateSignal(sig *dbus.Signal, conn *dbus.Conn) (*players.Player, map[str
 	return player, props, true
 }
 
-func handleNewTrack(player *players.Player, variant dbus.Variant, conn *dbus.Conn) {
+func handleNewTrack(player *players.Player, variant dbus.Variant, conn bus.Bus) {
 	if player.Title != "" {
-		go onTrackChange(*player)
+		finishListen(player)
 	}
 
 	player.ResetPlayTime()
+	player.ResetTrack()
 	player.UpdateMediaPlayerMetadata(variant, conn)
-	player.StartListeningTime = time.Now()
+	player.StartListeningTime = players.Now()
+	syncPosition(player, conn)
+
+	// Announce the track without blocking the signal loop
+	go scrobbler.NowPlaying(player.Snapshot())
+}
+
+// The track started over from the beginning, e.g. on repeat-one, so the previous play is a listen of its own
+func handleRestart(player *players.Player, position time.Duration) {
+	log.Printf("Track restarted: %s\n", player.Title)
+	finishListen(player)
+
+	player.ResetPlayTime()
+	player.Position = position
+	player.StartListeningTime = players.Now().Add(-position)
+
+	go scrobbler.NowPlaying(player.Snapshot())
+}
+
+// Love the track on scrobbling targets when the user gives it the highest rating
+func handleRating(player *players.Player, variant dbus.Variant) {
+	rating, _ := variant.Value().(float64)
+	if rating >= 1 && player.UserRating < 1 {
+		go scrobbler.Love(player.Snapshot())
+	}
+	player.UserRating = rating
+}
+
+func handleRate(player *players.Player, variant dbus.Variant) {
+	if rate, ok := variant.Value().(float64); ok {
+		player.SetRate(rate)
+	}
 }
 
-func handlePlaybackStatus(player *players.Player, status dbus.Variant) {
+func handlePlaybackStatus(player *players.Player, status dbus.Variant, conn bus.Bus) {
 	playbackStatus, _ := status.Value().(string)
-	switch playbackStatus {
-	case "Playing":
-		player.Play()
-	case "Stopped":
-		player.Pause()
+	if playbackStatus == player.Status {
+		// Polling repeats the current status
+		return
+	}
+
+	if err := player.SetPlaybackStatus(playbackStatus); err != nil {
+		log.Println(err)
+		return
 	}
+
+	// Players may move while paused or stopped without announcing a seek, e.g.
+	// when playing a track again after it finished. While the track is
+	// changing, the position may belong to either track.
+	_, pending := pendingTracks[player.Name]
+	if playbackStatus == players.StatusPlaying && !pending {
+		if isReplay(player, conn) {
+			handleRestart(player, 0)
+		} else {
+			syncPosition(player, conn)
+		}
+	}
+
 	log.Printf("Playback status changed: %s (%s)", playbackStatus, player.Title)
 }
 
-func onTrackChange(player players.Player) {
+// Correct the estimated position with the one reported by the player
+func syncPosition(player *players.Player, conn bus.Bus) {
+	position, err := player.GetTrackPosition(conn)
+	if err != nil {
+		// Position is optional for players, so keep the estimate
+		return
+	}
+	player.SyncPosition(position)
+}
+
+func onTrackChange(player players.Player, end time.Time) {
 	log.Printf("Total track play time: %v (%s)\n", player.GetTotalPlayTime(), player.Title)
 
 	db, err := database.NewDB()
@@ -123,37 +490,52 @@ func onTrackChange(player players.Player) {
 	}
 	defer db.Close()
 
-	tracks, err := db.GetTracks("name", fmt.Sprintf("%s", player.Title))
+	track, match, err := db.MatchTrack(player.TrackQuery())
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	if len(tracks) == 0 {
-		log.Println("track not found in database")
-		return
+	// Keep listens of tracks outside the library, e.g. streams
+	if track == nil {
+		track, err = db.AddRemoteTrack(player.TrackQuery())
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		match = database.TrackMatch{Method: database.MatchRemote, Confidence: 1}
+		log.Println("Added remote track to the database:", player.Title)
 	}
 
-	// Verify all duplicate track names by matching artist names
-	var track *database.Track
-	for _, t := range tracks {
-		for _, a := range t.Artists {
-			if strings.Contains(strings.Join(player.Artists, ","), a.Name) {
-				track = t
-			}
-		}
+	// Apply each scrobbler's rules before recording the listen, so it keeps the outcome
+	decisions, err := scrobbler.Decide(player)
+	if err != nil {
+		log.Println(err)
 	}
 
-	// Default to the first result if no track was found (artist might not be provided)
-	if track == nil {
-		track = tracks[0]
+	var allowed bool
+	rules := make(map[string]string, len(decisions))
+	for target, decision := range decisions {
+		rules[target] = decision.Rule
+		allowed = allowed || decision.Allowed
+		if !decision.Allowed {
+			log.Printf("Not scrobbling %s to %s: %s\n", player.Title, target, decision.Reason)
+		}
 	}
 
 	listen := database.Listen{
-		UserID:     1,
-		TrackID:    track.ID,
-		ListenTime: int(player.GetTotalPlayTime().Seconds()),
-		Timestamp:  time.Now(),
+		UserID:        1,
+		TrackID:       track.ID,
+		ListenTime:    int(player.GetTotalPlayTime().Seconds()),
+		Timestamp:     end,
+		ScrobbleRules: rules,
+		Match:         match,
+		Player:        player.Name,
+		StartTime:     player.StartListeningTime,
+		EndTime:       end,
+		SeekCount:     player.SeekCount,
+		IsSkip:        player.IsSkipped(),
+		Metadata:      player.Metadata,
 	}
 
 	err = db.AddListens([]*database.Listen{&listen})
@@ -162,35 +544,21 @@ func onTrackChange(player players.Player) {
 		return
 	}
 
-	log.Printf("Logged track listen to %s (%.0fs)", player.Title, player.GetTotalPlayTime().Seconds())
-
-	// Scrobble only if 50%+ of the track was listened to
-	listenedPercentage := (player.GetTotalPlayTime().Seconds() / float64(player.LengthSeconds)) * 100
-	if listenedPercentage > 50 {
-		var err error
-		player.MBID, err = musicbrainz.FetchMBID(player)
-		if err != nil {
-			log.Println(err)
-		}
+	log.Printf("Logged track listen to %s (%.0fs, matched by %s)", player.Title, player.GetTotalPlayTime().Seconds(), match.Method)
 
-		config, err := filesystem.GetConfigFile()
-		if err != nil {
-			log.Println(err)
-			return
-		}
+	if !allowed {
+		return
+	}
 
-		sessionKey, err := lastfm.Authenticate(config.LastFM)
+	if !scrobbler.Offline {
+		player.MBID, err = musicbrainz.FetchMBID(player)
 		if err != nil {
 			log.Println(err)
-			return
 		}
+	}
 
-		err = lastfm.Scrobble(player, config.LastFM.APIKey, config.LastFM.APISecret, sessionKey)
-		if err != nil {
-			log.Println(err)
-			return
-		}
-	} else {
-		log.Printf("Track listening time (%.0fs) is less than 50%% (%.0f%%) of the track length (%ds). Not scrobbling %s.\n", player.GetTotalPlayTime().Seconds(), listenedPercentage, player.LengthSeconds, player.Title)
+	// Queue the scrobble so it survives being offline or restarted
+	if err = scrobbler.Enqueue(player, decisions, listen.ID); err != nil {
+		log.Println(err)
 	}
 }
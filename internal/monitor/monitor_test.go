@@ -0,0 +1,510 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+	"gitlab.com/AlexJarrah/media-manager/internal/scrobbler"
+)
+
+const testPlayer = "org.mpris.MediaPlayer2.test"
+
+// Start of the tests' clock
+var testStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Network requests are skipped for good, as announcements run in goroutines that outlive a test
+func TestMain(m *testing.M) {
+	scrobbler.Offline = true
+	os.Exit(m.Run())
+}
+
+// Records the scrobbles submitted to it, accepting all of them
+type fakeScrobbler struct {
+	mu        sync.Mutex
+	scrobbles []*database.Scrobble
+}
+
+func (s *fakeScrobbler) Name() string { return "fake" }
+
+func (s *fakeScrobbler) NowPlaying(player players.Player) error { return nil }
+
+func (s *fakeScrobbler) Scrobble(scrobbles []*database.Scrobble) ([]error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scrobbles = append(s.scrobbles, scrobbles...)
+	return make([]error, len(scrobbles)), nil
+}
+
+func (s *fakeScrobbler) Love(player players.Player) error { return nil }
+
+func (s *fakeScrobbler) submitted() []*database.Scrobble {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*database.Scrobble(nil), s.scrobbles...)
+}
+
+// The signal loop running on a fake bus, with a clock that only moves when
+// the test advances it and a temporary config & database
+type testMonitor struct {
+	t         *testing.T
+	fake      *bus.Fake
+	c         chan *dbus.Signal
+	done      chan struct{}
+	clock     atomic.Int64 // Nanoseconds since testStart
+	scrobbler *fakeScrobbler
+}
+
+// Point the config & database to a temporary home, whitelisting testPlayer,
+// and submit scrobbles to the returned fake
+func newTestEnv(t *testing.T) *fakeScrobbler {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	configDir, err := filesystem.GetConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := []byte(`{"players": ["` + testPlayer + `"]}`)
+	if err = os.WriteFile(filepath.Join(configDir, "config.json"), config, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	database.Path = filepath.Join(home, "data.db")
+	t.Cleanup(func() { database.Path = "" })
+	if err = database.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeScrobbler{}
+	targets := scrobbler.Targets
+	scrobbler.Targets = func(internal.Config) []scrobbler.Target {
+		return []scrobbler.Target{{Scrobbler: fake}}
+	}
+	t.Cleanup(func() { scrobbler.Targets = targets })
+
+	players.Players = players.NewRegistry()
+	pendingTracks = make(map[string]*pendingTrack)
+	return fake
+}
+
+func newTestMonitor(t *testing.T) *testMonitor {
+	t.Helper()
+
+	m := &testMonitor{
+		t:         t,
+		fake:      bus.NewFake(),
+		c:         make(chan *dbus.Signal),
+		done:      make(chan struct{}),
+		scrobbler: newTestEnv(t),
+	}
+
+	players.Now = func() time.Time { return testStart.Add(time.Duration(m.clock.Load())) }
+	players.LoadSettings()
+	t.Cleanup(func() { players.Now = time.Now })
+
+	// Signals are unbuffered, so delivering one waits for the previous one to be handled
+	m.fake.Signal(m.c)
+	go func() {
+		handleSignals(m.c, nil, nil, m.fake)
+		close(m.done)
+	}()
+	t.Cleanup(m.stop)
+
+	return m
+}
+
+// Wait for the loop to handle every signal so far & for their listens to be saved
+func (m *testMonitor) sync() {
+	m.fake.Emit(&dbus.Signal{Name: "media-manager.Test.Sync"})
+	pendingListens.Wait()
+}
+
+func (m *testMonitor) advance(d time.Duration) {
+	m.sync()
+	m.clock.Add(int64(d))
+}
+
+// Stop the loop like a closed connection, saving the listens in progress
+func (m *testMonitor) stop() {
+	select {
+	case <-m.done:
+		return
+	default:
+	}
+
+	close(m.c)
+	<-m.done
+}
+
+func (m *testMonitor) listens() []*database.Listen {
+	m.t.Helper()
+	return testListens(m.t)
+}
+
+// Listens in the test database, in the order they started
+func testListens(t *testing.T) []*database.Listen {
+	t.Helper()
+
+	db, err := database.NewDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	listens, err := db.GetListens("ORDER BY start_time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listens
+}
+
+func trackMetadata(title string, length time.Duration) dbus.Variant {
+	return dbus.MakeVariant(map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath("/test/" + title)),
+		"mpris:length":  dbus.MakeVariant(length.Microseconds()),
+		"xesam:title":   dbus.MakeVariant(title),
+		"xesam:artist":  dbus.MakeVariant([]string{"Artist"}),
+		"xesam:album":   dbus.MakeVariant("Album"),
+	})
+}
+
+func TestMonitorRecordsListens(t *testing.T) {
+	m := newTestMonitor(t)
+
+	m.fake.AddPlayer(testPlayer)
+	m.fake.SetProperties(testPlayer, map[string]dbus.Variant{
+		"Metadata":       trackMetadata("First", 200*time.Second),
+		"PlaybackStatus": dbus.MakeVariant(players.StatusPlaying),
+	})
+
+	// Heard 160s of the first track, skipping from 60s to 100s
+	m.advance(60 * time.Second)
+	m.fake.Seek(testPlayer, (100 * time.Second).Microseconds())
+	m.advance(100 * time.Second)
+
+	// Skipped the second track after 30s, when the player quit
+	m.fake.SetProperties(testPlayer, map[string]dbus.Variant{
+		"Metadata": trackMetadata("Second", 300*time.Second),
+	})
+	m.advance(30 * time.Second)
+	m.fake.RemovePlayer(testPlayer)
+	m.stop()
+
+	if err := scrobbler.FlushQueue(); err != nil {
+		t.Fatal(err)
+	}
+
+	listens := m.listens()
+	if len(listens) != 2 {
+		t.Fatalf("got %d listens, want 2", len(listens))
+	}
+
+	want := []struct {
+		listenTime int
+		seekCount  int
+		isSkip     bool
+		rule       string
+		scrobbled  bool
+	}{
+		{160, 1, false, scrobbler.RulePlayedPercent, true},
+		{30, 0, true, scrobbler.RuleNotPlayedEnough, false},
+	}
+	for i, w := range want {
+		l := listens[i]
+		if l.ListenTime != w.listenTime || l.SeekCount != w.seekCount || l.IsSkip != w.isSkip {
+			t.Errorf("listen %d: got %ds, %d seeks, skip %v; want %ds, %d seeks, skip %v",
+				i, l.ListenTime, l.SeekCount, l.IsSkip, w.listenTime, w.seekCount, w.isSkip)
+		}
+		if l.Player != testPlayer {
+			t.Errorf("listen %d: got player %q, want %q", i, l.Player, testPlayer)
+		}
+		if l.Match.Method != database.MatchRemote {
+			t.Errorf("listen %d: got match %q, want %q", i, l.Match.Method, database.MatchRemote)
+		}
+		if l.ScrobbleRules["fake"] != w.rule {
+			t.Errorf("listen %d: got rule %q, want %q", i, l.ScrobbleRules["fake"], w.rule)
+		}
+		if scrobbled := len(l.ScrobbledServices) == 1 && l.ScrobbledServices[0] == "fake"; scrobbled != w.scrobbled {
+			t.Errorf("listen %d: got scrobbled services %v, want scrobbled %v", i, l.ScrobbledServices, w.scrobbled)
+		}
+	}
+
+	scrobbles := m.scrobbler.submitted()
+	if len(scrobbles) != 1 {
+		t.Fatalf("got %d scrobbles, want 1", len(scrobbles))
+	}
+	s := scrobbles[0]
+	if s.Track != "First" || s.Artist != "Artist" || s.Album != "Album" || s.Duration != 200 || !s.Timestamp.Equal(testStart) {
+		t.Errorf("got scrobble of %s by %s on %s (%ds) at %v, want First by Artist on Album (200s) at %v",
+			s.Track, s.Artist, s.Album, s.Duration, s.Timestamp, testStart)
+	}
+}
+
+// A PropertiesChanged signal as stored in recordings, seconds into the test
+type recordedProperties struct {
+	at    int
+	props string // Changed properties in the D-Bus text format
+}
+
+// Feed the properties through the loop as a recording of the player owning sender
+func (m *testMonitor) replay(sender string, steps []recordedProperties) {
+	m.t.Helper()
+
+	var lines bytes.Buffer
+	encoder := json.NewEncoder(&lines)
+	for _, step := range steps {
+		err := encoder.Encode(recordedSignal{
+			Time:   testStart.Add(time.Duration(step.at) * time.Second),
+			Sender: sender,
+			Path:   bus.MPRISPath,
+			Name:   signalPropertiesChanged,
+			Body: []recordedValue{
+				{Signature: "s", Value: `"` + bus.PlayerIface + `"`},
+				{Signature: "a{sv}", Value: step.props},
+				{Signature: "as", Value: "@as []"},
+			},
+		})
+		if err != nil {
+			m.t.Fatal(err)
+		}
+	}
+
+	recorded, err := readRecording(&lines)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+
+	for _, rs := range recorded {
+		m.sync()
+		m.clock.Store(int64(rs.time.Sub(testStart)))
+		m.fake.Replay(rs.signal)
+	}
+	m.sync()
+}
+
+func TestPlayTime(t *testing.T) {
+	const track = `{"Metadata": <{"mpris:length": <@x 300000000>, "xesam:artist": <["Artist"]>, "xesam:title": <"Song">}>, `
+
+	tests := []struct {
+		name  string
+		steps []recordedProperties
+		want  time.Duration
+	}{
+		{"pause & resume", []recordedProperties{
+			{0, track + `"PlaybackStatus": <"Playing">}`},
+			{60, `{"PlaybackStatus": <"Paused">}`},
+			{90, `{"PlaybackStatus": <"Playing">}`},
+			{150, `{"PlaybackStatus": <"Stopped">}`},
+		}, 120 * time.Second},
+		{"repeated statuses", []recordedProperties{
+			{0, track + `"PlaybackStatus": <"Playing">}`},
+			{30, `{"PlaybackStatus": <"Playing">}`},
+			{60, `{"PlaybackStatus": <"Paused">}`},
+			{75, `{"PlaybackStatus": <"Paused">}`},
+			{90, `{"PlaybackStatus": <"Playing">}`},
+			{150, `{"PlaybackStatus": <"Stopped">}`},
+			{180, `{"PlaybackStatus": <"Stopped">}`},
+		}, 120 * time.Second},
+		{"started paused", []recordedProperties{
+			{0, track + `"PlaybackStatus": <"Paused">}`},
+			{20, `{"PlaybackStatus": <"Playing">}`},
+			{60, `{"PlaybackStatus": <"Paused">}`},
+			{90, `{"PlaybackStatus": <"Playing">}`},
+			{150, `{"PlaybackStatus": <"Stopped">}`},
+		}, 100 * time.Second},
+		{"resumed elsewhere in the track", []recordedProperties{
+			{0, track + `"PlaybackStatus": <"Playing">}`},
+			{60, `{"PlaybackStatus": <"Paused">}`},
+			{90, `{"PlaybackStatus": <"Playing">, "Position": <@x 200000000>}`},
+			{150, `{"PlaybackStatus": <"Stopped">}`},
+		}, 120 * time.Second},
+		{"double rate", []recordedProperties{
+			{0, track + `"PlaybackStatus": <"Playing">, "Rate": <2.0>}`},
+			{30, `{"PlaybackStatus": <"Paused">}`},
+			{60, `{"PlaybackStatus": <"Playing">, "Rate": <1.0>}`},
+			{90, `{"PlaybackStatus": <"Stopped">}`},
+		}, 90 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMonitor(t)
+			owner := m.fake.AddPlayer(testPlayer)
+			m.replay(owner, tt.steps)
+
+			// Stopped, so the play time no longer grows
+			m.clock.Add(int64(time.Minute))
+			player, ok := players.Players.Get(testPlayer)
+			if !ok {
+				t.Fatal("player not found")
+			}
+			if got := player.GetTotalPlayTime(); got != tt.want {
+				t.Errorf("got play time %v, want %v", got, tt.want)
+			}
+
+			m.stop()
+			listens := m.listens()
+			if len(listens) != 1 || listens[0].ListenTime != int(tt.want.Seconds()) {
+				t.Errorf("got listens %+v, want one of %v", listens, tt.want)
+			}
+		})
+	}
+}
+
+func TestSenderResolvedAfterNameOwnerChanged(t *testing.T) {
+	m := newTestMonitor(t)
+
+	// Only the signals announce the player, the bus cannot look its name up
+	m.fake.Emit(&dbus.Signal{
+		Sender: "org.freedesktop.DBus",
+		Path:   "/org/freedesktop/DBus",
+		Name:   signalNameOwnerChanged,
+		Body:   []interface{}{testPlayer, "", ":1.42"},
+	})
+	m.fake.Emit(&dbus.Signal{
+		Sender: ":1.42",
+		Path:   bus.MPRISPath,
+		Name:   signalPropertiesChanged,
+		Body: []interface{}{bus.PlayerIface, map[string]dbus.Variant{
+			"Metadata":       trackMetadata("Song", 200*time.Second),
+			"PlaybackStatus": dbus.MakeVariant(players.StatusPlaying),
+		}, []string{}},
+	})
+	m.sync()
+
+	player, ok := players.Players.Get(testPlayer)
+	if !ok {
+		t.Fatal("the sender was not resolved to the player")
+	}
+	if player.Title != "Song" || player.Status != players.StatusPlaying {
+		t.Errorf("got %s (%s), want Song (%s)", player.Title, player.Status, players.StatusPlaying)
+	}
+}
+func TestChangesMetadata(t *testing.T) {
+	metadata := map[string]dbus.Variant{
+		"xesam:title":  dbus.MakeVariant("Song"),
+		"xesam:artist": dbus.MakeVariant([]string{"Artist"}),
+	}
+
+	tests := []struct {
+		name   string
+		update map[string]dbus.Variant
+		want   bool
+	}{
+		{"resent", map[string]dbus.Variant{"xesam:artist": dbus.MakeVariant([]string{"Artist"})}, false},
+		{"changed", map[string]dbus.Variant{"xesam:artist": dbus.MakeVariant([]string{"Other"})}, true},
+		{"added", map[string]dbus.Variant{"xesam:album": dbus.MakeVariant("Album")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changesMetadata(metadata, tt.update); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayDebounce(t *testing.T) {
+	newTestEnv(t)
+
+	// Overwrite the config, waiting 5s of the recording for metadata to settle
+	configDir, err := filesystem.GetConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := []byte(`{"players": ["` + testPlayer + `"], "player_settings": {"` + testPlayer + `": {"debounce_ms": 5000}}}`)
+	if err = os.WriteFile(filepath.Join(configDir, "config.json"), config, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The artist arrives after the title, and the player stops 60s in
+	signals := []recordedSignal{
+		{Time: testStart, Sender: "org.freedesktop.DBus", Path: "/org/freedesktop/DBus", Name: signalNameOwnerChanged, Body: []recordedValue{
+			{Signature: "s", Value: `"` + testPlayer + `"`},
+			{Signature: "s", Value: `""`},
+			{Signature: "s", Value: `":1.5"`},
+		}},
+		{Time: testStart, Sender: ":1.5", Path: bus.MPRISPath, Name: signalPropertiesChanged, Body: []recordedValue{
+			{Signature: "s", Value: `"` + bus.PlayerIface + `"`},
+			{Signature: "a{sv}", Value: `{"Metadata": <{"mpris:length": <@x 300000000>, "xesam:title": <"Song">}>, "PlaybackStatus": <"Playing">}`},
+			{Signature: "as", Value: "@as []"},
+		}},
+		{Time: testStart.Add(time.Second), Sender: ":1.5", Path: bus.MPRISPath, Name: signalPropertiesChanged, Body: []recordedValue{
+			{Signature: "s", Value: `"` + bus.PlayerIface + `"`},
+			{Signature: "a{sv}", Value: `{"Metadata": <{"mpris:length": <@x 300000000>, "xesam:artist": <["Artist"]>, "xesam:title": <"Song">}>}`},
+			{Signature: "as", Value: "@as []"},
+		}},
+		{Time: testStart.Add(time.Minute), Sender: ":1.5", Path: bus.MPRISPath, Name: signalPropertiesChanged, Body: []recordedValue{
+			{Signature: "s", Value: `"` + bus.PlayerIface + `"`},
+			{Signature: "a{sv}", Value: `{"PlaybackStatus": <"Stopped">}`},
+			{Signature: "as", Value: "@as []"},
+		}},
+	}
+
+	var lines bytes.Buffer
+	encoder := json.NewEncoder(&lines)
+	for _, sig := range signals {
+		if err = encoder.Encode(sig); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The replay takes 0.6s, so the track only settles if the delay follows the replay clock
+	if err = Replay(&lines, 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if listens := testListens(t); len(listens) != 1 {
+		t.Errorf("got %d listens, want the settled track's", len(listens))
+	}
+}
+
+func TestBootstrapPlayTime(t *testing.T) {
+	newTestEnv(t)
+
+	var clock time.Duration
+	players.Now = func() time.Time { return testStart.Add(clock) }
+	t.Cleanup(func() { players.Now = time.Now })
+
+	// Already 100s into the track when the monitor starts
+	fake := bus.NewFake()
+	fake.AddPlayer(testPlayer)
+	fake.SetQuietProperty(testPlayer, "Metadata", trackMetadata("Song", 200*time.Second))
+	fake.SetQuietProperty(testPlayer, "PlaybackStatus", dbus.MakeVariant(players.StatusPlaying))
+	fake.SetQuietProperty(testPlayer, "Position", dbus.MakeVariant((100 * time.Second).Microseconds()))
+	bootstrapPlayers(fake)
+
+	player, ok := players.Players.Get(testPlayer)
+	if !ok {
+		t.Fatal("player not found")
+	}
+
+	clock = 30 * time.Second
+	if got := player.GetTotalPlayTime(); got != 30*time.Second {
+		t.Errorf("got play time %v, want only the 30s heard since starting", got)
+	}
+	if !player.StartListeningTime.Equal(testStart.Add(-100 * time.Second)) {
+		t.Errorf("got start %v, want the track's start 100s earlier", player.StartListeningTime)
+	}
+}
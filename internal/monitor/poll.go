@@ -0,0 +1,180 @@
+package monitor
+
+import (
+	"log"
+	"maps"
+	"reflect"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Positions further than this from the estimate are treated as a seek the player did not announce
+const positionTolerance = 3 * time.Second
+
+// Properties read by a poller, handled like a PropertiesChanged signal
+type polledProperties struct {
+	name  string
+	props map[string]dbus.Variant
+}
+
+// A track change waiting for the player's metadata to settle
+type pendingTrack struct {
+	metadata   map[string]dbus.Variant
+	timer      *time.Timer
+	generation int
+}
+
+// Identifies the debounce timer that fired, as an earlier timer may fire after being replaced
+type settledTrack struct {
+	name       string
+	generation int
+}
+
+// Pending track changes by player name; only used from the signal loop
+var pendingTracks = make(map[string]*pendingTrack)
+
+// Receives the track changes whose metadata settled
+var settledTracks = make(chan settledTrack, 10)
+
+// Starts the debounce timers; replays run them on the recording's clock
+var afterFunc = time.AfterFunc
+
+// Start reading the properties of players that are configured to be polled
+func startPollers(conn bus.Bus, polls chan<- polledProperties) {
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for name, settings := range config.PlayerSettings {
+		if settings.PollSeconds > 0 {
+			go pollPlayer(name, time.Duration(settings.PollSeconds)*time.Second, conn, polls)
+		}
+	}
+}
+
+func pollPlayer(name string, interval time.Duration, conn bus.Bus, polls chan<- polledProperties) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		props, err := players.GetPlayerProperties(name, conn)
+		if err != nil {
+			// The player is not running
+			continue
+		}
+		polls <- polledProperties{name: name, props: props}
+	}
+}
+
+func handlePolledProperties(polled polledProperties, conn bus.Bus) {
+	player, err := players.GetPlayerByName(polled.name)
+	if err == nil {
+		handleProperties(player, polled.props, conn)
+		return
+	}
+
+	// Polling found the player before any of its signals did
+	player = &players.Player{Name: polled.name}
+	if !player.IsWhitelisted() {
+		return
+	}
+
+	players.Players.Add(player)
+	bootstrapPlayer(player, polled.props, conn)
+}
+
+// Polled properties from a recording, sent by the player's well-known name
+func handleReplayedPoll(sig *dbus.Signal, conn bus.Bus) {
+	if len(sig.Body) == 0 {
+		return
+	}
+
+	if props, ok := sig.Body[0].(map[string]dbus.Variant); ok {
+		handlePolledProperties(polledProperties{name: sig.Sender, props: props}, conn)
+	}
+}
+
+// Players that do not announce seeks are caught by comparing their position with the estimate
+func handlePosition(player *players.Player, variant dbus.Variant) {
+	microseconds, ok := variant.Value().(int64)
+	if !ok {
+		return
+	}
+	position := time.Duration(microseconds) * time.Microsecond
+
+	drift := position - player.GetPosition()
+	switch {
+	case player.Title == "":
+		player.SyncPosition(position)
+	case player.IsRestart(position):
+		handleRestart(player, position)
+	case drift > positionTolerance || drift < -positionTolerance:
+		player.Seek(position)
+	default:
+		player.SyncPosition(position)
+	}
+}
+
+// Wait for more metadata before handling a track change, as some players
+// update the title & artists in separate signals. Only changed metadata
+// restarts the wait, as polls & chatty players resend unchanged metadata.
+func debounceTrack(player *players.Player, metadata map[string]dbus.Variant, delay time.Duration) {
+	pending, ok := pendingTracks[player.Name]
+	if !ok {
+		pending = &pendingTrack{metadata: make(map[string]dbus.Variant)}
+		pendingTracks[player.Name] = pending
+	} else if !changesMetadata(pending.metadata, metadata) {
+		return
+	} else {
+		pending.timer.Stop()
+	}
+
+	maps.Copy(pending.metadata, metadata)
+	pending.generation++
+
+	settled := settledTrack{name: player.Name, generation: pending.generation}
+	pending.timer = afterFunc(delay, func() { settledTracks <- settled })
+}
+
+// Reports whether merging the update into the metadata changes any value
+func changesMetadata(metadata, update map[string]dbus.Variant) bool {
+	for key, value := range update {
+		old, ok := metadata[key]
+		if !ok || !reflect.DeepEqual(old.Value(), value.Value()) {
+			return true
+		}
+	}
+	return false
+}
+
+func handleSettledTrack(settled settledTrack, conn bus.Bus) {
+	pending, ok := pendingTracks[settled.name]
+	if !ok || pending.generation != settled.generation {
+		return
+	}
+	delete(pendingTracks, settled.name)
+
+	player, err := players.GetPlayerByName(settled.name)
+	if err != nil {
+		return
+	}
+
+	if isNewTrack(player, pending.metadata) {
+		handleNewTrack(player, dbus.MakeVariant(pending.metadata), conn)
+	}
+}
+
+// Forget a pending track change, e.g. when its player quit
+func dropPendingTrack(name string) {
+	if pending, ok := pendingTracks[name]; ok {
+		pending.timer.Stop()
+		delete(pendingTracks, name)
+	}
+}
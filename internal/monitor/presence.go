@@ -0,0 +1,28 @@
+package monitor
+
+import (
+	"gitlab.com/AlexJarrah/media-manager/internal/discord"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Show the most recently started track of a playing player on Discord, or clear the presence
+func updatePresence() {
+	var active *players.Player
+	for _, player := range players.Players.All() {
+		if player.Status != players.StatusPlaying || player.Title == "" {
+			continue
+		}
+
+		if active == nil || player.StartListeningTime.After(active.StartListeningTime) {
+			active = player
+		}
+	}
+
+	if active == nil {
+		discord.SetPresence(nil)
+		return
+	}
+
+	snapshot := active.Snapshot()
+	discord.SetPresence(&snapshot)
+}
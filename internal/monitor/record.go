@@ -0,0 +1,232 @@
+package monitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// A received signal, stored as one line of a recording
+type recordedSignal struct {
+	Time   time.Time       `json:"time"`
+	Sender string          `json:"sender"`
+	Path   dbus.ObjectPath `json:"path"`
+	Name   string          `json:"name"`
+	Body   []recordedValue `json:"body"`
+}
+
+// A signal argument in the D-Bus text format, e.g. {"signature": "a{sv}", "value": "{\"Rate\": <1.0>}"}
+type recordedValue struct {
+	Signature string `json:"signature"`
+	Value     string `json:"value"`
+}
+
+// Pseudo-signals for the monitor's other inputs, so replays see the same player state
+const (
+	signalPolled       = "media-manager.PolledProperties" // Body: properties
+	signalPropertyRead = bus.PropertyReadSignal
+)
+
+// Writes received signals while recording; only used from the signal loop
+var recording *json.Encoder
+
+// Monitor players like MonitorPlayers, writing every received signal to w as JSON Lines
+func Record(conn bus.Bus, w io.Writer) error {
+	recording = json.NewEncoder(w)
+	defer func() { recording = nil }()
+
+	return MonitorPlayers(recordingBus{conn})
+}
+
+// Records the property reads answering signals, e.g. a player's position after it resumed
+type recordingBus struct {
+	bus.Bus
+}
+
+func (b recordingBus) GetProperty(name, property string) (dbus.Variant, error) {
+	v, err := b.Bus.GetProperty(name, property)
+	if err == nil {
+		recordSignal(&dbus.Signal{
+			Sender: name,
+			Path:   bus.MPRISPath,
+			Name:   signalPropertyRead,
+			Body:   []interface{}{property, v},
+		})
+	}
+	return v, err
+}
+
+func recordPolledProperties(polled polledProperties) {
+	recordSignal(&dbus.Signal{
+		Sender: polled.name,
+		Path:   bus.MPRISPath,
+		Name:   signalPolled,
+		Body:   []interface{}{polled.props},
+	})
+}
+
+func recordSignal(sig *dbus.Signal) {
+	recorded := recordedSignal{
+		Time:   players.Now(),
+		Sender: sig.Sender,
+		Path:   sig.Path,
+		Name:   sig.Name,
+	}
+
+	for _, arg := range sig.Body {
+		v := dbus.MakeVariant(arg)
+		recorded.Body = append(recorded.Body, recordedValue{Signature: v.Signature().String(), Value: v.String()})
+	}
+
+	if err := recording.Encode(recorded); err != nil {
+		log.Println("Failed to record signal:", err)
+	}
+}
+
+// Start a recording with the players that are already running, as
+// signals announcing them & their current state
+func recordPlayers(conn bus.Bus) {
+	names, err := players.GetAllMediaPlayers(conn)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, name := range names {
+		owner, err := conn.GetNameOwner(name)
+		if err != nil {
+			continue
+		}
+
+		recordSignal(&dbus.Signal{
+			Sender: "org.freedesktop.DBus",
+			Path:   "/org/freedesktop/DBus",
+			Name:   signalNameOwnerChanged,
+			Body:   []interface{}{name, "", owner},
+		})
+
+		props, err := players.GetPlayerProperties(name, conn)
+		if err != nil {
+			continue
+		}
+
+		recordSignal(&dbus.Signal{
+			Sender: owner,
+			Path:   bus.MPRISPath,
+			Name:   signalPropertiesChanged,
+			Body:   []interface{}{bus.PlayerIface, props, []string{}},
+		})
+	}
+}
+
+// Feed a recording back through the monitor on a fake bus, speed times
+// faster than it was recorded. Play time & debounce delays follow the
+// recording's clock.
+func Replay(r io.Reader, speed float64) error {
+	if speed <= 0 {
+		return fmt.Errorf("invalid replay speed: %v", speed)
+	}
+
+	recorded, err := readRecording(r)
+	if err != nil {
+		return err
+	}
+	if len(recorded) == 0 {
+		return errors.New("the recording has no signals")
+	}
+
+	// Run the clock from the start of the recording, at the replay speed
+	start, replayStart := recorded[0].time, time.Now()
+	players.Now = func() time.Time {
+		return start.Add(time.Duration(float64(time.Since(replayStart)) * speed))
+	}
+	defer func() { players.Now = time.Now }()
+
+	afterFunc = func(d time.Duration, f func()) *time.Timer {
+		return time.AfterFunc(time.Duration(float64(d)/speed), f)
+	}
+	defer func() { afterFunc = time.AfterFunc }()
+
+	players.LoadSettings()
+
+	fake := bus.NewFake()
+	c := make(chan *dbus.Signal, 10)
+	fake.Signal(c)
+
+	go func() {
+		for i, rs := range recorded {
+			if rs.signal.Name == signalPropertyRead {
+				continue
+			}
+
+			at := time.Duration(float64(rs.time.Sub(start)) / speed)
+			time.Sleep(at - time.Since(replayStart))
+
+			// Reads following a signal answered it, so they must be in place before it is handled
+			for _, read := range recorded[i+1:] {
+				if read.signal.Name != signalPropertyRead {
+					break
+				}
+				fake.Replay(read.signal)
+			}
+			fake.Replay(rs.signal)
+		}
+
+		// Signals are delivered synchronously, so every signal is in c by now.
+		// Closing it ends the replay once they are all handled.
+		close(c)
+	}()
+
+	log.Printf("Replaying %d signals...\n", len(recorded))
+	handleSignals(c, nil, nil, fake)
+	return nil
+}
+
+type replayedSignal struct {
+	time   time.Time
+	signal *dbus.Signal
+}
+
+func readRecording(r io.Reader) ([]replayedSignal, error) {
+	var signals []replayedSignal
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var recorded recordedSignal
+		if err := json.Unmarshal(scanner.Bytes(), &recorded); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		sig := &dbus.Signal{Sender: recorded.Sender, Path: recorded.Path, Name: recorded.Name}
+		for _, arg := range recorded.Body {
+			signature, err := dbus.ParseSignature(arg.Signature)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+
+			v, err := dbus.ParseVariant(arg.Value, signature)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			sig.Body = append(sig.Body, v.Value())
+		}
+
+		signals = append(signals, replayedSignal{time: recorded.Time, signal: sig})
+	}
+
+	return signals, scanner.Err()
+}
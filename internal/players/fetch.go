@@ -5,19 +5,20 @@ import (
 	"strings"
 
 	"github.com/godbus/dbus/v5"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
 )
 
-func GetAllMediaPlayers(conn *dbus.Conn) (players []string, err error) {
+func GetAllMediaPlayers(conn bus.Bus) (players []string, err error) {
 	// List all names registered on the session bus
-	var names []string
-	err = conn.BusObject().Call("org.freedesktop.DBus.ListNames", 0).Store(&names)
+	names, err := conn.ListNames()
 	if err != nil {
 		return []string{}, err
 	}
 
 	// Filter names to include only MediaPlayer names
 	for _, name := range names {
-		if strings.HasPrefix(name, "org.mpris.MediaPlayer2.") {
+		if strings.HasPrefix(name, bus.MPRISPrefix) {
 			players = append(players, name)
 		}
 	}
@@ -25,82 +26,67 @@ func GetAllMediaPlayers(conn *dbus.Conn) (players []string, err error) {
 	return players, nil
 }
 
+// Get all MPRIS player properties, e.g. Metadata, PlaybackStatus & Position
+func GetPlayerProperties(name string, conn bus.Bus) (map[string]dbus.Variant, error) {
+	props, err := conn.GetAllProperties(name, bus.PlayerIface)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get properties of %s: %v", name, err)
+	}
+
+	return props, nil
+}
+
 func GetPlayerByName(name string) (*Player, error) {
-	for _, p := range *Players {
-		if name == p.Name {
-			return p, nil
-		}
+	if p, ok := Players.Get(name); ok {
+		return p, nil
 	}
 
 	return nil, fmt.Errorf("no player found with name: %s", name)
 }
 
-func GetPlayerBySignal(sender string, conn *dbus.Conn) (*Player, error) {
-	obj := conn.Object("org.freedesktop.DBus", "/org/freedesktop/DBus")
-	var name string
-	err := obj.Call("org.freedesktop.DBus.GetNameOwner", 0, sender).Store(&name)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get name owner: %v", err)
-	}
+// Stop tracking a player, e.g. after it quit
+func RemovePlayer(name string) {
+	Players.Remove(name)
+}
 
-	if name == "" {
-		return nil, fmt.Errorf("no name found for sender %s", sender)
-	}
+// Returns the player's name without the MPRIS bus name prefix, e.g. "spotify"
+func (p *Player) ShortName() string {
+	return strings.TrimPrefix(p.Name, bus.MPRISPrefix)
+}
 
-	for _, p := range *Players {
-		var owner string
-		err = obj.Call("org.freedesktop.DBus.GetNameOwner", 0, p.Name).Store(&owner)
-		if err == nil && owner == name {
-			return p, nil
+// Get the player that sent a signal. Signals carry the sender's unique bus
+// name, which is mapped to its well-known MPRIS name through a cache that
+// NameOwnerChanged keeps up to date.
+func GetPlayerBySignal(sender string, conn bus.Bus) (*Player, error) {
+	name, ok := Players.NameForOwner(sender)
+	if !ok {
+		if err := refreshOwners(conn); err != nil {
+			return nil, err
 		}
-	}
-
-	players, err := GetAllMediaPlayers(conn)
-	if err != nil {
-		return nil, err
-	}
 
-	for _, p := range players {
-		var owner string
-		err = obj.Call("org.freedesktop.DBus.GetNameOwner", 0, p).Store(&owner)
-		if err == nil && owner == name {
-			player := &Player{Name: p}
-			*Players = append(*Players, player)
-			return player, nil
+		if name, ok = Players.NameForOwner(sender); !ok {
+			return nil, fmt.Errorf("no MPRIS name found for sender %s", sender)
 		}
 	}
 
-	return nil, fmt.Errorf("no MPRIS name found for sender %s", sender)
+	return Players.GetOrAdd(name), nil
 }
 
-func getPlayerName(conn *dbus.Conn, sender string) (string, error) {
-	obj := conn.Object("org.freedesktop.DBus", "/org/freedesktop/DBus")
-	var name string
-	err := obj.Call("org.freedesktop.DBus.GetNameOwner", 0, sender).Store(&name)
+// Look up the owners of all MPRIS names, for players that appeared before NameOwnerChanged was watched
+func refreshOwners(conn bus.Bus) error {
+	names, err := GetAllMediaPlayers(conn)
 	if err != nil {
-		return "", fmt.Errorf("failed to get name owner: %v", err)
+		return err
 	}
 
-	if name == "" {
-		return "", fmt.Errorf("no name found for sender %s", sender)
-	}
-
-	// Now we need to get the well-known name for this unique name
-	var names []string
-	err = obj.Call("org.freedesktop.DBus.ListNames", 0).Store(&names)
-	if err != nil {
-		return "", fmt.Errorf("failed to list names: %v", err)
-	}
-
-	for _, n := range names {
-		if strings.HasPrefix(n, "org.mpris.MediaPlayer2.") {
-			var owner string
-			err = obj.Call("org.freedesktop.DBus.GetNameOwner", 0, n).Store(&owner)
-			if err == nil && owner == name {
-				return n, nil
-			}
+	for _, name := range names {
+		owner, err := conn.GetNameOwner(name)
+		if err != nil {
+			// The player may have quit in the meantime
+			continue
 		}
+		Players.SetOwner(name, "", owner)
 	}
 
-	return "", fmt.Errorf("no MPRIS name found for sender %s", sender)
+	return nil
 }
@@ -0,0 +1,29 @@
+package players
+
+import (
+	"net/url"
+	"path/filepath"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+)
+
+// Returns the local file the player is playing, from its file:// URL. Empty
+// for streams & players that do not report their track's location.
+func (p *Player) FilePath() string {
+	u, err := url.Parse(p.URL)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return ""
+	}
+	return filepath.Clean(u.Path)
+}
+
+// Describe the player's track, to find it in the library
+func (p *Player) TrackQuery() database.TrackQuery {
+	return database.TrackQuery{
+		FilePath: p.FilePath(),
+		Title:    p.Title,
+		Artists:  p.Artists,
+		Album:    p.Album,
+		Duration: int(p.LengthSeconds),
+	}
+}
@@ -0,0 +1,105 @@
+package players
+
+import (
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Registry of known players. Player state is only changed by the signal
+// loop that owns it; other goroutines must work on snapshots instead.
+type Registry struct {
+	mu      sync.RWMutex
+	players map[string]*Player // Players by well-known name, e.g. org.mpris.MediaPlayer2.spotify
+	owners  map[string]string  // Well-known names by unique bus name, e.g. :1.42
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		players: make(map[string]*Player),
+		owners:  make(map[string]string),
+	}
+}
+
+// Get a player by its well-known name
+func (r *Registry) Get(name string) (*Player, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.players[name]
+	return p, ok
+}
+
+// Get a player by its well-known name, adding it when it is not known yet
+func (r *Registry) GetOrAdd(name string) *Player {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if p, ok := r.players[name]; ok {
+		return p
+	}
+
+	p := &Player{Name: name}
+	r.players[name] = p
+	return p
+}
+
+func (r *Registry) Add(p *Player) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.players[p.Name] = p
+}
+
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.players, name)
+}
+
+// Returns all players, sorted by name
+func (r *Registry) All() []*Player {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]*Player, 0, len(r.players))
+	for _, p := range r.players {
+		all = append(all, p)
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Returns the well-known name owned by a unique bus name
+func (r *Registry) NameForOwner(owner string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	name, ok := r.owners[owner]
+	return name, ok
+}
+
+// Record a change of name ownership, as announced by NameOwnerChanged
+func (r *Registry) SetOwner(name, oldOwner, newOwner string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if oldOwner != "" && r.owners[oldOwner] == name {
+		delete(r.owners, oldOwner)
+	}
+	if newOwner != "" {
+		r.owners[newOwner] = name
+	}
+}
+
+// Returns a copy of the player that is safe to use from other goroutines
+func (p *Player) Snapshot() Player {
+	snapshot := *p
+	snapshot.Artists = slices.Clone(p.Artists)
+	snapshot.Genres = slices.Clone(p.Genres)
+	snapshot.Metadata = maps.Clone(p.Metadata)
+	return snapshot
+}
@@ -0,0 +1,84 @@
+package players
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
+)
+
+// Run with -race: the registry is shared between the signal loop & background work
+func TestRegistryConcurrency(t *testing.T) {
+	r := NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("org.mpris.MediaPlayer2.player%d", i)
+		owner := fmt.Sprintf(":1.%d", i)
+
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				r.GetOrAdd(name)
+				r.SetOwner(name, "", owner)
+				r.SetOwner(name, owner, "")
+				r.Remove(name)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				for _, p := range r.All() {
+					_ = p.Snapshot()
+				}
+				r.Get(name)
+				r.NameForOwner(owner)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if all := r.All(); len(all) != 0 {
+		t.Errorf("got %d players after removing all of them", len(all))
+	}
+}
+
+func TestGetPlayerBySignal(t *testing.T) {
+	Players = NewRegistry()
+	t.Cleanup(func() { Players = NewRegistry() })
+
+	const name = "org.mpris.MediaPlayer2.test"
+	conn := bus.NewFake()
+
+	// Owners announced by NameOwnerChanged resolve without asking the bus, which does not know the name
+	Players.SetOwner(name, "", ":1.5")
+	player, err := GetPlayerBySignal(":1.5", conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if player.Name != name {
+		t.Errorf("got player %s, want %s", player.Name, name)
+	}
+
+	// The player restarted under another unique name
+	Players.SetOwner(name, ":1.5", ":1.6")
+	if _, err = GetPlayerBySignal(":1.5", conn); err == nil {
+		t.Error("the previous owner still resolves to the player")
+	}
+	if p, err := GetPlayerBySignal(":1.6", conn); err != nil || p != player {
+		t.Errorf("got %v, %v for the new owner, want the same player", p, err)
+	}
+
+	// Players that appeared before NameOwnerChanged was watched are looked up on the bus
+	other := "org.mpris.MediaPlayer2.other"
+	owner := conn.AddPlayer(other)
+	p, err := GetPlayerBySignal(owner, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != other {
+		t.Errorf("got player %s, want %s", p.Name, other)
+	}
+}
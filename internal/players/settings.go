@@ -0,0 +1,35 @@
+package players
+
+import (
+	"log"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+)
+
+// Workaround settings by player name, as loaded by LoadSettings
+var settings map[string]internal.PlayerSettings
+
+// Load the players' workaround settings from the config. They are needed on
+// every metadata update, so they are read once when monitoring starts.
+func LoadSettings() {
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		log.Println(err)
+		settings = nil
+		return
+	}
+
+	settings = config.PlayerSettings
+}
+
+// Get the player's workaround settings
+func (p *Player) Settings() internal.PlayerSettings {
+	return settings[p.Name]
+}
+
+// Returns how long to wait for the player's metadata to settle
+func (p *Player) DebounceDelay() time.Duration {
+	return time.Duration(p.Settings().DebounceMillis) * time.Millisecond
+}
@@ -4,52 +4,170 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/godbus/dbus/v5"
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
 )
 
+// MPRIS playback statuses
+const (
+	StatusPlaying = "Playing"
+	StatusPaused  = "Paused"
+	StatusStopped = "Stopped"
+)
+
+// Follow the player's playback status, only counting play time while playing
+func (p *Player) SetPlaybackStatus(status string) error {
+	switch status {
+	case StatusPlaying:
+		p.Play()
+	case StatusPaused, StatusStopped:
+		p.Pause()
+	default:
+		return fmt.Errorf("unknown playback status: %s", status)
+	}
+
+	p.Status = status
+	return nil
+}
+
+// Current time; replaced to replay recordings faster than real time
+var Now = time.Now
+
+// Tracks left earlier than this before their end were skipped
+const skipMargin = 10 * time.Second
+
+// Thresholds for telling a restart of the track from an ordinary seek
+const (
+	restartMaxPosition = 2 * time.Second  // Seeking to before this is a restart...
+	restartMinPosition = 10 * time.Second // ...if the track had played past this
+)
+
+// Reports whether the track was left before its end. Tracks of unknown length
+// are never considered skipped.
+func (p *Player) IsSkipped() bool {
+	if p.LengthSeconds <= 0 {
+		return false
+	}
+	return p.GetPosition() < time.Duration(p.LengthSeconds)*time.Second-skipMargin
+}
+
 // Start/resume calculation of track play time
 func (p *Player) Play() {
 	if !p.IsPlaying {
 		p.IsPlaying = true
-		p.LastPlayStart = time.Now()
+		p.LastPlayStart = Now()
 	}
 }
 
 // Pause calculation of track play time
 func (p *Player) Pause() {
 	if p.IsPlaying {
+		p.checkpoint()
 		p.IsPlaying = false
-		p.TotalPlayTime += time.Since(p.LastPlayStart)
 	}
 }
 
 // Reset track play time
 func (p *Player) ResetPlayTime() {
 	p.TotalPlayTime = 0
-	p.LastPlayStart = time.Now()
+	p.LastPlayStart = Now()
+	p.Position = 0
+	p.SeekCount = 0
 }
 
-// Calculate track play time
+// Calculate track play time, i.e. the amount of audio heard
 func (p *Player) GetTotalPlayTime() time.Duration {
 	if p.IsPlaying {
-		return p.TotalPlayTime + time.Since(p.LastPlayStart)
+		return p.TotalPlayTime + p.sinceLastPlayStart()
 	}
 	return p.TotalPlayTime
 }
 
+// Estimate the current track position
+func (p *Player) GetPosition() time.Duration {
+	if p.IsPlaying {
+		return p.Position + p.sinceLastPlayStart()
+	}
+	return p.Position
+}
+
+// Change the playback rate; audio heard so far counts at the previous rate
+func (p *Player) SetRate(rate float64) {
+	p.checkpoint()
+	p.Rate = rate
+}
+
+// Move to a new track position without counting the skipped audio as heard
+func (p *Player) Seek(position time.Duration) {
+	p.checkpoint()
+	p.Position = position
+	p.SeekCount++
+}
+
+// Reports whether moving to position means the track started over
+func (p *Player) IsRestart(position time.Duration) bool {
+	return position < restartMaxPosition && p.GetPosition() >= restartMinPosition
+}
+
+// Correct the estimated track position without counting it as a seek
+func (p *Player) SyncPosition(position time.Duration) {
+	p.checkpoint()
+	p.Position = position
+}
+
+// Add the audio heard since LastPlayStart to the play time & position
+func (p *Player) checkpoint() {
+	if p.IsPlaying {
+		heard := p.sinceLastPlayStart()
+		p.TotalPlayTime += heard
+		p.Position += heard
+	}
+	p.LastPlayStart = Now()
+}
+
+// Audio heard since LastPlayStart, taking the playback rate into account
+func (p *Player) sinceLastPlayStart() time.Duration {
+	return time.Duration(float64(Now().Sub(p.LastPlayStart)) * p.PlaybackRate())
+}
+
+// Returns the playback rate, where an unknown rate is normal speed
+func (p *Player) PlaybackRate() float64 {
+	if p.Rate <= 0 {
+		return 1
+	}
+	return p.Rate
+}
+
+// Estimate when the track would have started, had it played at the current
+// rate without pauses or seeks. Stays the same while the track plays on.
+func (p *Player) PlaybackStart() time.Time {
+	from := p.LastPlayStart
+	if !p.IsPlaying {
+		from = Now()
+	}
+	return from.Add(-time.Duration(float64(p.Position) / p.PlaybackRate()))
+}
+
+// Estimate when the track will end, or the zero time if its length is unknown
+func (p *Player) PlaybackEnd() time.Time {
+	if p.LengthSeconds <= 0 {
+		return time.Time{}
+	}
+	length := time.Duration(p.LengthSeconds) * time.Second
+	return p.PlaybackStart().Add(time.Duration(float64(length) / p.PlaybackRate()))
+}
+
 // Get player's current track position
-func (p *Player) GetTrackPosition(conn *dbus.Conn) (float64, error) {
-	obj := conn.Object(p.Name, "/org/mpris/MediaPlayer2")
-	variant, err := obj.GetProperty("org.mpris.MediaPlayer2.Player.Position")
+func (p *Player) GetTrackPosition(conn bus.Bus) (time.Duration, error) {
+	variant, err := conn.GetProperty(p.Name, bus.PlayerIface+".Position")
 	if err != nil {
 		return 0, fmt.Errorf("failed to get Position property: %v", err)
 	}
 
-	// Position is in microseconds, convert to seconds
+	// Position is in microseconds
 	positionMicroseconds, ok := variant.Value().(int64)
 	if !ok {
 		return 0, fmt.Errorf("Position is not in the expected format")
 	}
 
-	return float64(positionMicroseconds) / 1000000, nil
+	return time.Duration(positionMicroseconds) * time.Microsecond, nil
 }
@@ -3,15 +3,24 @@ package players
 import "time"
 
 type Player struct {
-	Name               string        // Name of the player
-	MBID               string        // MusicBrainz track ID
-	StartListeningTime time.Time     // Time the track started playing
-	TotalPlayTime      time.Duration // Total listening time; use GetTotalPlayTime() for accurate calculations
-	IsPlaying          bool          // If the track is currently playing
-	LastPlayStart      time.Time     // Time when track was last started/resumed
-	Album              string        // Album title
-	Artists            []string      // Track artists
-	ArtURL             string        // Album art URL
-	LengthSeconds      int64         // Track length in seconds
-	Title              string        // Track Title
+	Name               string         // Name of the player
+	MBID               string         // MusicBrainz track ID
+	StartListeningTime time.Time      // Time the track started playing
+	TotalPlayTime      time.Duration  // Total listening time; use GetTotalPlayTime() for accurate calculations
+	IsPlaying          bool           // If the track is currently playing
+	Status             string         // MPRIS playback status: Playing, Paused or Stopped
+	LastPlayStart      time.Time      // Time when track was last started/resumed, or play time last checkpointed
+	Position           time.Duration  // Track position at LastPlayStart; use GetPosition() for the current position
+	Rate               float64        // Playback rate; 0 is treated as 1
+	SeekCount          int            // Number of seeks during the current listen
+	Album              string         // Album title
+	Artists            []string       // Track artists
+	Genres             []string       // Track genres
+	ArtURL             string         // Album art URL
+	LengthSeconds      int64          // Track length in seconds
+	Title              string         // Track Title
+	TrackID            string         // MPRIS track ID, unique per playlist entry
+	URL                string         // Track location, e.g. a file:// or web URL
+	UserRating         float64        // User rating from 0 to 1, if supported by the player
+	Metadata           map[string]any // Raw MPRIS metadata of the track, as plain values
 }
@@ -1,23 +1,86 @@
 package players
 
-import "github.com/godbus/dbus/v5"
+import (
+	"github.com/godbus/dbus/v5"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/bus"
+)
 
 // Update the player's track metadata
-func (p *Player) UpdateMediaPlayerMetadata(variant dbus.Variant, conn *dbus.Conn) {
+func (p *Player) UpdateMediaPlayerMetadata(variant dbus.Variant, conn bus.Bus) {
 	metadata := variant.Value().(map[string]dbus.Variant)
+	if p.Metadata == nil {
+		p.Metadata = make(map[string]any, len(metadata))
+	}
+
 	for key, value := range metadata {
 		v := value.Value()
+		p.Metadata[key] = plainValue(v)
+
 		switch key {
 		case "xesam:album":
 			p.Album = v.(string)
 		case "xesam:artist":
 			p.Artists = v.([]string)
+		case "xesam:genre":
+			p.Genres, _ = v.([]string)
 		case "mpris:artUrl":
 			p.ArtURL = v.(string)
 		case "mpris:length":
 			p.LengthSeconds = v.(int64) / 1000000
+		case "mpris:trackid":
+			p.TrackID = TrackID(value)
 		case "xesam:title":
 			p.Title = v.(string)
+		case "xesam:url":
+			p.URL, _ = v.(string)
+		case "xesam:userRating":
+			p.UserRating, _ = v.(float64)
+		}
+	}
+}
+
+// Forget the previous track's metadata, so fields the next track lacks,
+// e.g. the length of a stream, are not carried over
+func (p *Player) ResetTrack() {
+	p.MBID, p.Title, p.Album, p.ArtURL = "", "", "", ""
+	p.Artists, p.Genres = nil, nil
+	p.LengthSeconds = 0
+	p.TrackID, p.URL = "", ""
+	p.UserRating = 0
+	p.Metadata = nil
+}
+
+// Convert a D-Bus value to plain Go values, so it can be stored as JSON
+func plainValue(value any) any {
+	switch v := value.(type) {
+	case dbus.Variant:
+		return plainValue(v.Value())
+	case dbus.ObjectPath:
+		return string(v)
+	case map[string]dbus.Variant:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[key] = plainValue(value.Value())
+		}
+		return m
+	case []dbus.Variant:
+		values := make([]any, len(v))
+		for i, value := range v {
+			values[i] = plainValue(value.Value())
 		}
+		return values
+	}
+	return value
+}
+
+// Returns the MPRIS track ID, which some players send as a string instead of an object path
+func TrackID(variant dbus.Variant) string {
+	switch id := variant.Value().(type) {
+	case dbus.ObjectPath:
+		return string(id)
+	case string:
+		return id
 	}
+	return ""
 }
@@ -1,3 +1,3 @@
 package players
 
-var Players *[]*Player = &[]*Player{}
+var Players = NewRegistry()
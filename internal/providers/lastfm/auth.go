@@ -2,12 +2,93 @@ package lastfm
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
 )
 
-// Get last.fm session key
+// Web auth polling settings; tokens are valid for 60 minutes
+var (
+	SessionPollInterval = 3 * time.Second
+	SessionPollTimeout  = 10 * time.Minute
+)
+
+// Get a last.fm session key, reusing the cached key when available
+func GetSessionKey(config internal.LastFM) (sessionKey string, err error) {
+	sessions, err := filesystem.GetSessions()
+	if err != nil {
+		return "", err
+	}
+
+	if sessionKey, ok := sessions[sessionCacheKey(config)]; ok && sessionKey != "" {
+		return sessionKey, nil
+	}
+
+	if config.Password == "" {
+		return "", errors.New("no Last.fm session, log in with the `media-manager auth` command")
+	}
+
+	// Session keys never expire, so authenticating is only needed once
+	sessionKey, err = Authenticate(config)
+	if err != nil {
+		return "", err
+	}
+
+	if err = cacheSessionKey(config, sessionKey); err != nil {
+		log.Println("Failed to cache Last.fm session key:", err)
+	}
+
+	return sessionKey, nil
+}
+
+// Guards the read, change & write of the session cache, as now playing
+// updates, scrobbles & loves are sent concurrently
+var sessionsMu sync.Mutex
+
+// Remove the cached session key, e.g. after Last.fm reports it as invalid
+func ClearSessionKey(config internal.LastFM) error {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	sessions, err := filesystem.GetSessions()
+	if err != nil {
+		return err
+	}
+
+	delete(sessions, sessionCacheKey(config))
+	return filesystem.WriteSessions(sessions)
+}
+
+func cacheSessionKey(config internal.LastFM, sessionKey string) error {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	sessions, err := filesystem.GetSessions()
+	if err != nil {
+		return err
+	}
+
+	sessions[sessionCacheKey(config)] = sessionKey
+	return filesystem.WriteSessions(sessions)
+}
+
+// Session keys belong to a user on a server, so they are cached per username and server
+func sessionCacheKey(config internal.LastFM) string {
+	if config.APIURL == "" {
+		return "lastfm/" + config.Username
+	}
+	return strings.TrimSuffix(config.APIURL, "/") + "/" + config.Username
+}
+
+// Get last.fm session key using the account password
 func Authenticate(config internal.LastFM) (sessionKey string, err error) {
 	authParams := map[string]string{
 		"method":   "auth.getMobileSession",
@@ -18,18 +99,110 @@ func Authenticate(config internal.LastFM) (sessionKey string, err error) {
 	authParams["api_sig"] = generateAPISignature(authParams, config.APISecret)
 	authParams["format"] = "json"
 
-	response, err := makePostRequest(authParams)
+	response, err := makePostRequest(apiURL(config), authParams)
 	if err != nil {
 		return "", err
 	}
 
-	// Parse the session key from the response
-	var sessionKeyResponse SessionKeyResponse
-	err = json.Unmarshal(response, &sessionKeyResponse)
+	return parseSessionKey(response)
+}
+
+// Get a session key by having the user authorize the app in their browser, caching the result
+func WebAuthenticate(config internal.LastFM, out io.Writer) (sessionKey string, err error) {
+	token, err := GetToken(config)
 	if err != nil {
-		return "", fmt.Errorf("Error parsing session key: %s", err.Error())
+		return "", err
+	}
+
+	fmt.Fprintf(out, "Open the following URL to allow access to your Last.fm account:\n\n  %s\n\nWaiting for authorization...\n", AuthorizationURL(config, token))
+
+	// Poll until the user authorizes the token or it expires
+	deadline := time.Now().Add(SessionPollTimeout)
+	for {
+		sessionKey, err = GetSession(config, token)
+		if err == nil {
+			break
+		}
+
+		if !errors.Is(err, ErrUnauthorizedToken) {
+			return "", err
+		}
+
+		if time.Now().After(deadline) {
+			return "", errors.New("timed out waiting for Last.fm authorization")
+		}
+		time.Sleep(SessionPollInterval)
+	}
+
+	if err = cacheSessionKey(config, sessionKey); err != nil {
+		return "", err
 	}
 
-	sessionKey = sessionKeyResponse.Session.Key
 	return sessionKey, nil
 }
+
+// Get an unauthorized request token for the web auth flow
+func GetToken(config internal.LastFM) (token string, err error) {
+	tokenParams := map[string]string{
+		"method":  "auth.getToken",
+		"api_key": config.APIKey,
+	}
+	tokenParams["api_sig"] = generateAPISignature(tokenParams, config.APISecret)
+	tokenParams["format"] = "json"
+
+	response, err := makePostRequest(apiURL(config), tokenParams)
+	if err != nil {
+		return "", err
+	}
+
+	var tokenResponse TokenResponse
+	if err = json.Unmarshal(response, &tokenResponse); err != nil {
+		return "", fmt.Errorf("Error parsing token: %s", err.Error())
+	}
+
+	if tokenResponse.Token == "" {
+		return "", fmt.Errorf("no token in Last.fm response: %s", string(response))
+	}
+
+	return tokenResponse.Token, nil
+}
+
+// Returns the page where the user authorizes a request token
+func AuthorizationURL(config internal.LastFM, token string) string {
+	params := url.Values{}
+	params.Set("api_key", config.APIKey)
+	params.Set("token", token)
+	return authURL(config) + "?" + params.Encode()
+}
+
+// Exchange an authorized request token for a session key
+func GetSession(config internal.LastFM, token string) (sessionKey string, err error) {
+	sessionParams := map[string]string{
+		"method":  "auth.getSession",
+		"token":   token,
+		"api_key": config.APIKey,
+	}
+	sessionParams["api_sig"] = generateAPISignature(sessionParams, config.APISecret)
+	sessionParams["format"] = "json"
+
+	response, err := makePostRequest(apiURL(config), sessionParams)
+	if err != nil {
+		return "", err
+	}
+
+	return parseSessionKey(response)
+}
+
+// Parse the session key from an auth response
+func parseSessionKey(response []byte) (string, error) {
+	var sessionKeyResponse SessionKeyResponse
+	if err := json.Unmarshal(response, &sessionKeyResponse); err != nil {
+		return "", fmt.Errorf("Error parsing session key: %s", err.Error())
+	}
+
+	if sessionKeyResponse.Session.Key == "" {
+		return "", fmt.Errorf("no session key in Last.fm response: %s", string(response))
+	}
+
+	return sessionKeyResponse.Session.Key, nil
+}
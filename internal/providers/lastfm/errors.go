@@ -0,0 +1,109 @@
+package lastfm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Last.fm API error codes, see https://www.last.fm/api/errorcodes
+const (
+	ErrorInvalidService         = 2
+	ErrorInvalidMethod          = 3
+	ErrorAuthenticationFailed   = 4
+	ErrorInvalidFormat          = 5
+	ErrorInvalidParameters      = 6
+	ErrorInvalidResource        = 7
+	ErrorOperationFailed        = 8
+	ErrorInvalidSessionKey      = 9
+	ErrorInvalidAPIKey          = 10
+	ErrorServiceOffline         = 11
+	ErrorInvalidSignature       = 13
+	ErrorUnauthorizedToken      = 14
+	ErrorTokenExpired           = 15
+	ErrorTemporarilyUnavailable = 16
+	ErrorLoginRequired          = 17
+	ErrorSuspendedAPIKey        = 26
+	ErrorRateLimitExceeded      = 29
+)
+
+// Reasons a scrobble within an accepted request was ignored
+const (
+	IgnoredArtist             = 1
+	IgnoredTrack              = 2
+	IgnoredTimestampTooOld    = 3
+	IgnoredTimestampTooNew    = 4
+	IgnoredDailyLimitExceeded = 5
+)
+
+var (
+	// Returned when the session key is invalid and the user must re-authenticate
+	ErrInvalidSession = &APIError{Code: ErrorInvalidSessionKey, Message: "Invalid session key"}
+
+	// Returned while the user has not yet authorized a web auth token
+	ErrUnauthorizedToken = &APIError{Code: ErrorUnauthorizedToken, Message: "Unauthorized token"}
+)
+
+// APIError is an error reported by the Last.fm API
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Last.fm error %d: %s", e.Code, e.Message)
+}
+
+// Errors with the same code are equal, so they can be matched with errors.Is
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Code == e.Code
+}
+
+// Retryable reports whether the same request may succeed later without the
+// user's intervention. Errors that are not retryable don't mean the data sent
+// was bad: credential errors are fixed in the config, after which queued
+// scrobbles can still be submitted.
+func (e *APIError) Retryable() bool {
+	switch e.Code {
+	case ErrorOperationFailed, ErrorServiceOffline, ErrorTemporarilyUnavailable, ErrorRateLimitExceeded:
+		return true
+	case ErrorInvalidSessionKey:
+		// Succeeds once a new session key has been obtained
+		return true
+	case ErrorAuthenticationFailed, ErrorInvalidAPIKey, ErrorInvalidSignature, ErrorSuspendedAPIKey:
+		// The API key or secret in the config must be fixed first
+		return false
+	default:
+		return false
+	}
+}
+
+// HTTPError is returned for unsuccessful responses that carry no Last.fm error
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("Last.fm HTTP error %d: %s", e.StatusCode, e.Body)
+}
+
+// IsRetryable reports whether a failed request may succeed later. Network errors and
+// server-side failures are retryable; errors caused by the request itself are not.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Retryable()
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == 429
+	}
+
+	return true
+}
@@ -0,0 +1,28 @@
+package lastfm
+
+import (
+	"fmt"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Mark the player's current track as loved
+func Love(player players.Player, config internal.LastFM, sessionKey string) error {
+	if len(player.Artists) == 0 {
+		return fmt.Errorf("no artists to love %s by", player.Title)
+	}
+
+	loveParams := map[string]string{
+		"method":  "track.love",
+		"artist":  player.Artists[0],
+		"track":   player.Title,
+		"api_key": config.APIKey,
+		"sk":      sessionKey,
+	}
+	loveParams["api_sig"] = generateAPISignature(loveParams, config.APISecret)
+	loveParams["format"] = "json"
+
+	_, err := makePostRequest(apiURL(config), loveParams)
+	return err
+}
@@ -0,0 +1,53 @@
+package lastfm
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Notify Last.fm that the player has started playing a new track
+func UpdateNowPlaying(player players.Player, config internal.LastFM, sessionKey string) error {
+	if len(player.Artists) == 0 {
+		return fmt.Errorf("no artists to update now playing for %s", player.Title)
+	}
+
+	nowPlayingParams := map[string]string{
+		"method":  "track.updateNowPlaying",
+		"artist":  player.Artists[0],
+		"track":   player.Title,
+		"api_key": config.APIKey,
+		"sk":      sessionKey,
+	}
+	if player.Album != "" {
+		nowPlayingParams["album"] = player.Album
+	}
+	if player.MBID != "" {
+		nowPlayingParams["mbid"] = player.MBID
+	}
+	if player.LengthSeconds > 0 {
+		nowPlayingParams["duration"] = fmt.Sprint(player.LengthSeconds)
+	}
+	nowPlayingParams["api_sig"] = generateAPISignature(nowPlayingParams, config.APISecret)
+	nowPlayingParams["format"] = "json"
+
+	body, err := makePostRequest(apiURL(config), nowPlayingParams)
+	if err != nil {
+		return err
+	}
+
+	var nowPlayingResponse NowPlayingResponse
+	if err = json.Unmarshal(body, &nowPlayingResponse); err != nil {
+		return fmt.Errorf("Error parsing now playing response: %s", err.Error())
+	}
+
+	if ignored, reason := nowPlayingResponse.NowPlaying.Ignored(); ignored {
+		return fmt.Errorf("Last.fm ignored now playing update for %s: %s", player.Title, reason)
+	}
+
+	log.Printf("Updated Last.fm now playing: %s\n", player.Title)
+	return nil
+}
@@ -1,32 +1,60 @@
 package lastfm
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
 
-	"gitlab.com/AlexJarrah/media-manager/internal/players"
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
 )
 
-func Scrobble(player players.Player, apiKey, apiSecret, sessionKey string) error {
+// Maximum number of scrobbles accepted by a single track.scrobble request
+const MaxScrobbleBatch = 50
+
+// Submit up to MaxScrobbleBatch scrobbles in a single request
+func Scrobble(scrobbles []*database.Scrobble, config internal.LastFM, sessionKey string) (*ScrobbleResponse, error) {
+	if len(scrobbles) > MaxScrobbleBatch {
+		return nil, fmt.Errorf("too many scrobbles in batch: %d (max %d)", len(scrobbles), MaxScrobbleBatch)
+	}
+
 	scrobbleParams := map[string]string{
-		"method":    "track.scrobble",
-		"artist":    player.Artists[0],
-		"track":     player.Title,
-		"timestamp": fmt.Sprint(player.StartListeningTime.Unix()),
-		"album":     player.Album,
-		"mbid":      player.MBID,
-		"duration":  fmt.Sprint(player.GetTotalPlayTime().Seconds()),
-		"api_key":   apiKey,
-		"sk":        sessionKey,
+		"method":  "track.scrobble",
+		"api_key": config.APIKey,
+		"sk":      sessionKey,
+	}
+
+	// Batches use the indexed form of each parameter, e.g. artist[0], track[0]
+	for i, s := range scrobbles {
+		scrobbleParams[fmt.Sprintf("artist[%d]", i)] = s.Artist
+		scrobbleParams[fmt.Sprintf("track[%d]", i)] = s.Track
+		scrobbleParams[fmt.Sprintf("timestamp[%d]", i)] = fmt.Sprint(s.Timestamp.Unix())
+		if s.Album != "" {
+			scrobbleParams[fmt.Sprintf("album[%d]", i)] = s.Album
+		}
+		if s.MBID != "" {
+			scrobbleParams[fmt.Sprintf("mbid[%d]", i)] = s.MBID
+		}
+		if s.Duration > 0 {
+			scrobbleParams[fmt.Sprintf("duration[%d]", i)] = fmt.Sprint(s.Duration)
+		}
 	}
-	scrobbleParams["api_sig"] = generateAPISignature(scrobbleParams, apiSecret)
+	scrobbleParams["api_sig"] = generateAPISignature(scrobbleParams, config.APISecret)
 	scrobbleParams["format"] = "json"
 
-	scrobbleResponse, err := makePostRequest(scrobbleParams)
+	body, err := makePostRequest(apiURL(config), scrobbleParams)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var scrobbleResponse ScrobbleResponse
+	if err = json.Unmarshal(body, &scrobbleResponse); err != nil {
+		return nil, fmt.Errorf("Error parsing scrobble response: %s", err.Error())
+	}
+
+	// Results are returned in submission order, anything else is an error response
+	if len(scrobbleResponse.Scrobbles.Scrobble) != len(scrobbles) {
+		return nil, fmt.Errorf("unexpected Last.fm response: %s", string(body))
 	}
 
-	log.Println("Last.fm response:", string(scrobbleResponse))
-	return nil
+	return &scrobbleResponse, nil
 }
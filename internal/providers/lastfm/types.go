@@ -1,8 +1,99 @@
 package lastfm
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // Struct to parse the session key response
 type SessionKeyResponse struct {
 	Session struct {
 		Key string `json:"key"`
 	} `json:"session"`
 }
+
+// Struct to parse the web auth token response
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+// Struct to parse error responses
+type ErrorResponse struct {
+	Error   int    `json:"error"`
+	Message string `json:"message"`
+}
+
+// Struct to parse the scrobble response
+type ScrobbleResponse struct {
+	Scrobbles struct {
+		Scrobble ScrobbleResults `json:"scrobble"`
+		Attr     struct {
+			Accepted json.Number `json:"accepted"`
+			Ignored  json.Number `json:"ignored"`
+		} `json:"@attr"`
+	} `json:"scrobbles"`
+}
+
+// Number of scrobbles accepted by Last.fm
+func (r ScrobbleResponse) Accepted() int {
+	n, _ := r.Scrobbles.Attr.Accepted.Int64()
+	return int(n)
+}
+
+// Number of scrobbles ignored by Last.fm
+func (r ScrobbleResponse) Ignored() int {
+	n, _ := r.Scrobbles.Attr.Ignored.Int64()
+	return int(n)
+}
+
+// Struct to parse the now playing response
+type NowPlayingResponse struct {
+	NowPlaying ScrobbleResult `json:"nowplaying"`
+}
+
+// Result of a single scrobble within a batch, or of a now playing update
+type ScrobbleResult struct {
+	IgnoredMessage IgnoredMessage `json:"ignoredMessage"`
+}
+
+// Reason for ignoring a scrobble; a code of 0 means it was accepted
+type IgnoredMessage struct {
+	Code json.Number `json:"code"`
+	Text string      `json:"#text"`
+}
+
+// One of the Ignored* codes, or 0 if the scrobble was accepted
+func (r ScrobbleResult) IgnoredCode() int {
+	code, _ := r.IgnoredMessage.Code.Int64()
+	return int(code)
+}
+
+// Ignored reports whether Last.fm ignored the scrobble, along with its reason
+func (r ScrobbleResult) Ignored() (bool, string) {
+	code := r.IgnoredCode()
+	if code == 0 {
+		return false, ""
+	}
+
+	reason := r.IgnoredMessage.Text
+	if reason == "" {
+		reason = fmt.Sprintf("ignored with code %d", code)
+	}
+	return true, reason
+}
+
+// Scrobble results are an object for single scrobbles and an array for batches
+type ScrobbleResults []ScrobbleResult
+
+func (r *ScrobbleResults) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '[' {
+		return json.Unmarshal(data, (*[]ScrobbleResult)(r))
+	}
+
+	var result ScrobbleResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	*r = ScrobbleResults{result}
+	return nil
+}
@@ -3,10 +3,14 @@ package lastfm
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/url"
 	"sort"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
 )
 
 // Generate API signature
@@ -27,16 +31,39 @@ func generateAPISignature(params map[string]string, secret string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// Make a POST request to the last.fm API
-func makePostRequest(params map[string]string) ([]byte, error) {
-	const apiURL = "https://ws.audioscrobbler.com/2.0/"
+// Default endpoints, overridable per config for Last.fm-compatible servers and local stand-ins
+const (
+	defaultAPIURL  = "https://ws.audioscrobbler.com/2.0/"
+	defaultAuthURL = "https://www.last.fm/api/auth/"
+)
+
+// Returns the configured API URL, or the Last.fm API by default
+func apiURL(config internal.LastFM) string {
+	if config.APIURL != "" {
+		return config.APIURL
+	}
+	return defaultAPIURL
+}
 
+// Returns the configured authorization page URL, or the Last.fm page by default
+func authURL(config internal.LastFM) string {
+	if config.AuthURL != "" {
+		return config.AuthURL
+	}
+	return defaultAuthURL
+}
+
+// Timeout for last.fm API requests
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Make a POST request to the last.fm API
+func makePostRequest(apiURL string, params map[string]string) ([]byte, error) {
 	data := url.Values{}
 	for key, value := range params {
 		data.Set(key, value)
 	}
 
-	resp, err := http.PostForm(apiURL, data)
+	resp, err := httpClient.PostForm(apiURL, data)
 	if err != nil {
 		return nil, err
 	}
@@ -47,5 +74,15 @@ func makePostRequest(params map[string]string) ([]byte, error) {
 		return nil, err
 	}
 
+	// Last.fm reports errors in the response body, regardless of the HTTP status
+	var errorResponse ErrorResponse
+	if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error != 0 {
+		return nil, &APIError{Code: errorResponse.Error, Message: errorResponse.Message}
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
 	return body, nil
 }
@@ -0,0 +1,31 @@
+package listenbrainz
+
+import (
+	"fmt"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Feedback scores accepted by the recording feedback endpoint
+const (
+	FeedbackLove = 1
+	FeedbackNone = 0
+	FeedbackHate = -1
+)
+
+// Struct to build a recording feedback request
+type Feedback struct {
+	RecordingMBID string `json:"recording_mbid"`
+	Score         int    `json:"score"`
+}
+
+// Mark the player's current track as loved; requires its MusicBrainz recording ID
+func Love(config internal.ListenBrainz, player players.Player) error {
+	if player.MBID == "" {
+		return fmt.Errorf("no MusicBrainz recording ID to love %s by", player.Title)
+	}
+
+	_, err := makePostRequest(config, "/1/feedback/recording-feedback", Feedback{RecordingMBID: player.MBID, Score: FeedbackLove})
+	return err
+}
@@ -0,0 +1,107 @@
+package listenbrainz
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Maximum number of listens accepted by a single import request
+const MaxListensPerRequest = 1000
+
+// Submit finished listens, as a single listen or an import depending on their count
+func SubmitListens(config internal.ListenBrainz, listens []Listen) error {
+	if len(listens) == 0 {
+		return nil
+	}
+	if len(listens) > MaxListensPerRequest {
+		return fmt.Errorf("too many listens in request: %d (max %d)", len(listens), MaxListensPerRequest)
+	}
+
+	listenType := ListenTypeImport
+	if len(listens) == 1 {
+		listenType = ListenTypeSingle
+	}
+
+	_, err := makePostRequest(config, "/1/submit-listens", Submission{ListenType: listenType, Payload: listens})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Submitted %d listen(s) to ListenBrainz\n", len(listens))
+	return nil
+}
+
+// Notify ListenBrainz that the player has started playing a new track
+func PlayingNow(config internal.ListenBrainz, player players.Player) error {
+	if len(player.Artists) == 0 {
+		return fmt.Errorf("no artists to submit playing now for %s", player.Title)
+	}
+
+	listen := Listen{
+		TrackMetadata: TrackMetadata{
+			ArtistName:  player.Artists[0],
+			TrackName:   player.Title,
+			ReleaseName: player.Album,
+			AdditionalInfo: AdditionalInfo{
+				RecordingMBID:    player.MBID,
+				DurationMS:       player.LengthSeconds * 1000,
+				MediaPlayer:      player.ShortName(),
+				OriginURL:        OriginURL(player.URL),
+				ArtistNames:      player.Artists,
+				SubmissionClient: internal.APP_NAME,
+			},
+		},
+	}
+
+	_, err := makePostRequest(config, "/1/submit-listens", Submission{ListenType: ListenTypePlayingNow, Payload: []Listen{listen}})
+	return err
+}
+
+// Convert a queued scrobble to a listen
+func ListenFromScrobble(scrobble *database.Scrobble) Listen {
+	return Listen{
+		ListenedAt: scrobble.Timestamp.Unix(),
+		TrackMetadata: TrackMetadata{
+			ArtistName:  scrobble.Artist,
+			TrackName:   scrobble.Track,
+			ReleaseName: scrobble.Album,
+			AdditionalInfo: AdditionalInfo{
+				RecordingMBID:    scrobble.MBID,
+				DurationMS:       int64(scrobble.Duration) * 1000,
+				MediaPlayer:      scrobble.Player,
+				OriginURL:        OriginURL(scrobble.OriginURL),
+				SubmissionClient: internal.APP_NAME,
+			},
+		},
+	}
+}
+
+// Returns the track URL if it can be opened by others, i.e. is not a local file
+func OriginURL(trackURL string) string {
+	u, err := url.Parse(trackURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return trackURL
+}
+
+// IsRetryable reports whether a failed request may succeed later
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Retryable()
+	}
+
+	// Network errors
+	return true
+}
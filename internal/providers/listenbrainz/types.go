@@ -0,0 +1,42 @@
+package listenbrainz
+
+// Listen types accepted by the submit-listens endpoint
+const (
+	ListenTypeSingle     = "single"
+	ListenTypeImport     = "import"
+	ListenTypePlayingNow = "playing_now"
+)
+
+// Struct to build a submit-listens request
+type Submission struct {
+	ListenType string   `json:"listen_type"`
+	Payload    []Listen `json:"payload"`
+}
+
+// A single listen; playing now submissions have no timestamp
+type Listen struct {
+	ListenedAt    int64         `json:"listened_at,omitempty"`
+	TrackMetadata TrackMetadata `json:"track_metadata"`
+}
+
+type TrackMetadata struct {
+	ArtistName     string         `json:"artist_name"`
+	TrackName      string         `json:"track_name"`
+	ReleaseName    string         `json:"release_name,omitempty"`
+	AdditionalInfo AdditionalInfo `json:"additional_info"`
+}
+
+type AdditionalInfo struct {
+	RecordingMBID    string   `json:"recording_mbid,omitempty"`
+	DurationMS       int64    `json:"duration_ms,omitempty"`
+	MediaPlayer      string   `json:"media_player,omitempty"`
+	OriginURL        string   `json:"origin_url,omitempty"`
+	ArtistNames      []string `json:"artist_names,omitempty"`
+	SubmissionClient string   `json:"submission_client,omitempty"`
+}
+
+// Struct to parse error responses
+type ErrorResponse struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,87 @@
+package listenbrainz
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+)
+
+// Default server, overridable per config for self-hosted instances and local stand-ins
+const defaultAPIURL = "https://api.listenbrainz.org"
+
+// Returns the configured API URL, or listenbrainz.org by default
+func apiURL(config internal.ListenBrainz) string {
+	if config.APIURL != "" {
+		return strings.TrimSuffix(config.APIURL, "/")
+	}
+	return defaultAPIURL
+}
+
+// HTTPError is an unsuccessful response from the ListenBrainz API
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("ListenBrainz error %d: %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the same request may succeed later
+func (e *HTTPError) Retryable() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= 500:
+		return true
+	case e.StatusCode == http.StatusUnauthorized:
+		// Succeeds once the token has been fixed in the config
+		return true
+	default:
+		return false
+	}
+}
+
+// Timeout for ListenBrainz API requests
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Make an authenticated POST request with a JSON body to the ListenBrainz API
+func makePostRequest(config internal.ListenBrainz, path string, payload any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, apiURL(config)+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Token "+config.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errorResponse ErrorResponse
+		message := string(body)
+		if json.Unmarshal(body, &errorResponse) == nil && errorResponse.Error != "" {
+			message = errorResponse.Error
+		}
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Message: message}
+	}
+
+	return body, nil
+}
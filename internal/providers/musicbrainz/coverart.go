@@ -0,0 +1,49 @@
+package musicbrainz
+
+import (
+	"errors"
+	"net/url"
+)
+
+type coverArtImage struct {
+	Image      string            `json:"image"`
+	Front      bool              `json:"front"`
+	Thumbnails map[string]string `json:"thumbnails"`
+}
+
+type coverArt struct {
+	Images []coverArtImage `json:"images"`
+}
+
+// Thumbnail sizes to use, most preferred first; full images can be huge
+var thumbnailSizes = []string{"500", "large", "250", "small"}
+
+// Returns the URL of the release's front cover from the Cover Art Archive, or
+// an empty string when it has none
+func FetchCoverArt(releaseMBID string) (string, error) {
+	if releaseMBID == "" {
+		return "", errors.New("No release to fetch cover art for")
+	}
+
+	var data coverArt
+	err := getJSON(coverArtURL()+"release/"+url.PathEscape(releaseMBID), &data)
+	if errors.Is(err, errNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	for _, image := range data.Images {
+		if !image.Front {
+			continue
+		}
+		for _, size := range thumbnailSizes {
+			if thumbnail := image.Thumbnails[size]; thumbnail != "" {
+				return thumbnail, nil
+			}
+		}
+		return image.Image, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,48 @@
+package musicbrainz
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+type release struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+type releaseSearch struct {
+	Releases []release `json:"releases"`
+}
+
+// Search for the release of the player's album. Releases titled exactly like
+// the album are preferred over MusicBrainz's fuzzier matches.
+func FetchReleaseMBID(player players.Player) (string, error) {
+	if player.Album == "" {
+		return "", errors.New("No album to search releases by")
+	}
+
+	query := "release:" + quote(player.Album)
+	if len(player.Artists) > 0 {
+		query += " AND artist:" + quote(player.Artists[0])
+	}
+	url := fmt.Sprintf("%srelease?query=%s&fmt=json", apiURL(), url.QueryEscape(query))
+
+	var data releaseSearch
+	if err := getJSON(url, &data); err != nil {
+		return "", err
+	}
+
+	if len(data.Releases) == 0 {
+		return "", errors.New("No releases found")
+	}
+	for _, r := range data.Releases {
+		if strings.EqualFold(r.Title, player.Album) {
+			return r.ID, nil
+		}
+	}
+	return data.Releases[0].ID, nil
+}
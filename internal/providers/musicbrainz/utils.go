@@ -0,0 +1,90 @@
+package musicbrainz
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+)
+
+const (
+	defaultAPIURL      = "https://musicbrainz.org/ws/2/"
+	defaultCoverArtURL = "https://coverartarchive.org/"
+)
+
+// Returned by getJSON for missing resources, e.g. releases without cover art
+var errNotFound = errors.New("Not found")
+
+// MusicBrainz asks clients to identify themselves
+const userAgent = internal.APP_ID + " ( https://gitlab.com/AlexJarrah/media-manager )"
+
+func getConfig() internal.MusicBrainz {
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		log.Println(err)
+		return internal.MusicBrainz{}
+	}
+	return config.MusicBrainz
+}
+
+// Returns the configured API URL, ending in a slash
+func apiURL() string {
+	if url := getConfig().APIURL; url != "" {
+		return strings.TrimSuffix(url, "/") + "/"
+	}
+	return defaultAPIURL
+}
+
+// Returns the configured Cover Art Archive URL, ending in a slash
+func coverArtURL() string {
+	if url := getConfig().CoverArtURL; url != "" {
+		return strings.TrimSuffix(url, "/") + "/"
+	}
+	return defaultCoverArtURL
+}
+
+// Timeout for MusicBrainz & Cover Art Archive requests
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Make a GET request and decode its JSON response into v. Returns
+// errNotFound when the resource does not exist.
+func getJSON(url string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error making GET request: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return errNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %d from %s: %s", resp.StatusCode, url, strings.TrimSpace(string(body)))
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("Error decoding JSON: %s", err.Error())
+	}
+	return nil
+}
+
+// Quote a value for a search query, escaping characters Lucene treats specially
+func quote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
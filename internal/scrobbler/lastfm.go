@@ -0,0 +1,93 @@
+package scrobbler
+
+import (
+	"errors"
+	"log"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+	"gitlab.com/AlexJarrah/media-manager/internal/providers/lastfm"
+)
+
+// Scrobbles to Last.fm, or to Libre.fm & other GNU FM servers through their compatible API
+type lastFMScrobbler struct {
+	name   string
+	config internal.LastFM
+}
+
+func (s *lastFMScrobbler) Name() string {
+	return s.name
+}
+
+func (s *lastFMScrobbler) BatchSize() int {
+	return lastfm.MaxScrobbleBatch
+}
+
+func (s *lastFMScrobbler) NowPlaying(player players.Player) error {
+	sessionKey, err := lastfm.GetSessionKey(s.config)
+	if err != nil {
+		return err
+	}
+
+	return s.checkSession(lastfm.UpdateNowPlaying(player, s.config, sessionKey))
+}
+
+func (s *lastFMScrobbler) Scrobble(scrobbles []*database.Scrobble) ([]error, error) {
+	sessionKey, err := lastfm.GetSessionKey(s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := lastfm.Scrobble(scrobbles, s.config, sessionKey)
+	if err != nil {
+		return nil, classify(s.checkSession(err))
+	}
+
+	results := make([]error, len(scrobbles))
+	for i, result := range response.Scrobbles.Scrobble {
+		ignored, reason := result.Ignored()
+		switch {
+		case !ignored:
+		case result.IgnoredCode() == lastfm.IgnoredDailyLimitExceeded:
+			// The daily limit resets, so these are retried instead of rejected
+			results[i] = errors.New(reason)
+		default:
+			results[i] = &PermanentError{Err: errors.New(reason)}
+		}
+	}
+
+	log.Printf("Scrobbled %d track(s) to %s (%d accepted, %d ignored)\n",
+		len(scrobbles), s.name, response.Accepted(), response.Ignored())
+	return results, nil
+}
+
+func (s *lastFMScrobbler) Love(player players.Player) error {
+	sessionKey, err := lastfm.GetSessionKey(s.config)
+	if err != nil {
+		return err
+	}
+
+	return s.checkSession(lastfm.Love(player, s.config, sessionKey))
+}
+
+// Drop the cached session key when it is reported as invalid, to re-authenticate next time
+func (s *lastFMScrobbler) checkSession(err error) error {
+	if errors.Is(err, lastfm.ErrInvalidSession) {
+		if err := lastfm.ClearSessionKey(s.config); err != nil {
+			log.Println(err)
+		}
+	}
+	return err
+}
+
+// Errors caused by the scrobble data are permanent, so the queue splits the
+// batch to find the bad scrobble. Other errors are about the request or the
+// account, e.g. a bad API key, and are retried as they are fixed in the config.
+func classify(err error) error {
+	var apiErr *lastfm.APIError
+	if errors.As(err, &apiErr) && (apiErr.Code == lastfm.ErrorInvalidParameters || apiErr.Code == lastfm.ErrorInvalidResource) {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
@@ -0,0 +1,48 @@
+package scrobbler
+
+import (
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+	"gitlab.com/AlexJarrah/media-manager/internal/providers/listenbrainz"
+)
+
+// Submits listens to ListenBrainz or a self-hosted instance
+type listenBrainzScrobbler struct {
+	name   string
+	config internal.ListenBrainz
+}
+
+func (s *listenBrainzScrobbler) Name() string {
+	return s.name
+}
+
+func (s *listenBrainzScrobbler) BatchSize() int {
+	return 100
+}
+
+func (s *listenBrainzScrobbler) NowPlaying(player players.Player) error {
+	return listenbrainz.PlayingNow(s.config, player)
+}
+
+// The whole request is rejected for a single invalid listen, so there are no per-listen results
+func (s *listenBrainzScrobbler) Scrobble(scrobbles []*database.Scrobble) ([]error, error) {
+	listens := make([]listenbrainz.Listen, len(scrobbles))
+	for i, scrobble := range scrobbles {
+		listens[i] = listenbrainz.ListenFromScrobble(scrobble)
+	}
+
+	err := listenbrainz.SubmitListens(s.config, listens)
+	if err != nil && !listenbrainz.IsRetryable(err) {
+		return nil, &PermanentError{Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return make([]error, len(scrobbles)), nil
+}
+
+func (s *listenBrainzScrobbler) Love(player players.Player) error {
+	return listenbrainz.Love(s.config, player)
+}
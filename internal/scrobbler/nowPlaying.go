@@ -0,0 +1,66 @@
+package scrobbler
+
+import (
+	"log"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+	"gitlab.com/AlexJarrah/media-manager/internal/providers/musicbrainz"
+)
+
+// Skip all network requests, e.g. while replaying a recording. Scrobbles are still queued.
+var Offline bool
+
+// Announce a newly started track to every target; run in its own goroutine as it makes network requests
+func NowPlaying(player players.Player) {
+	if Offline {
+		return
+	}
+
+	var err error
+	player.MBID, err = musicbrainz.FetchMBID(player)
+	if err != nil {
+		log.Println(err)
+	}
+
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, target := range Targets(config) {
+		if err := target.NowPlaying(player); err != nil {
+			log.Printf("Failed to update %s now playing: %v\n", target.Name(), err)
+		}
+	}
+}
+
+// Mark a track as loved on every target; run in its own goroutine as it makes network requests
+func Love(player players.Player) {
+	if Offline {
+		return
+	}
+
+	var err error
+	if player.MBID == "" {
+		player.MBID, err = musicbrainz.FetchMBID(player)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, target := range Targets(config) {
+		if err := target.Love(player); err != nil {
+			log.Printf("Failed to love %s on %s: %v\n", player.Title, target.Name(), err)
+		} else {
+			log.Printf("Loved %s on %s\n", player.Title, target.Name())
+		}
+	}
+}
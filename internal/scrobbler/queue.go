@@ -0,0 +1,243 @@
+package scrobbler
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+const (
+	// How often the queue is checked for scrobbles due for submission
+	queueInterval = time.Minute
+
+	// Scrobbles per request for targets that don't specify a batch size
+	defaultBatchSize = 50
+
+	// Bounds of the exponential backoff between failed submissions
+	minBackoff = time.Minute
+	maxBackoff = 6 * time.Hour
+)
+
+// Wakes the worker when new scrobbles are queued
+var wake = make(chan struct{}, 1)
+
+// Apply each target's scrobble rules to a finished listen, by target name
+func Decide(player players.Player) (map[string]Decision, error) {
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	length := time.Duration(player.LengthSeconds) * time.Second
+	decisions := make(map[string]Decision)
+	for _, target := range Targets(config) {
+		decisions[target.Name()] = Eligible(target.Rules, length, player.GetTotalPlayTime())
+	}
+	return decisions, nil
+}
+
+// Add a finished listen to the queue of every target that allowed it and wake the worker.
+// The listen is marked as scrobbled to each target that accepts it.
+func Enqueue(player players.Player, decisions map[string]Decision, listenID int64) error {
+	if len(player.Artists) == 0 || player.Title == "" {
+		return fmt.Errorf("not enough metadata to scrobble %q", player.Title)
+	}
+
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// Each target gets its own entry so they succeed or fail independently.
+	// Timestamps are stored in UTC at second precision so duplicates compare equal.
+	var scrobbles []*database.Scrobble
+	for _, target := range Targets(config) {
+		if !decisions[target.Name()].Allowed {
+			continue
+		}
+
+		scrobbles = append(scrobbles, &database.Scrobble{
+			Service:     target.Name(),
+			Artist:      player.Artists[0],
+			Track:       player.Title,
+			Album:       player.Album,
+			MBID:        player.MBID,
+			Duration:    int(player.LengthSeconds),
+			Timestamp:   player.StartListeningTime.UTC().Truncate(time.Second),
+			Player:      player.ShortName(),
+			OriginURL:   player.URL,
+			Status:      database.ScrobblePending,
+			NextAttempt: time.Now().UTC().Truncate(time.Second),
+			ListenID:    listenID,
+		})
+	}
+
+	if err = db.AddScrobbles(scrobbles); err != nil {
+		return err
+	}
+
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Submit queued scrobbles in the background, retrying failed submissions with backoff
+func ProcessQueue() {
+	ticker := time.NewTicker(queueInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := FlushQueue(); err != nil {
+			log.Println(err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-wake:
+		}
+	}
+}
+
+// Submit every due scrobble of each target in batches
+func FlushQueue() error {
+	config, err := filesystem.GetConfigFile()
+	if err != nil {
+		return err
+	}
+
+	db, err := database.NewDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// A failing target must not hold up the others
+	var errs []error
+	for _, target := range Targets(config) {
+		errs = append(errs, flushTarget(db, target.Scrobbler))
+	}
+	return errors.Join(errs...)
+}
+
+// Submit a target's due scrobbles until its queue is drained or a batch fails
+func flushTarget(db *database.DB, target Scrobbler) error {
+	size := defaultBatchSize
+	if b, ok := target.(interface{ BatchSize() int }); ok {
+		size = b.BatchSize()
+	}
+
+	for {
+		now := time.Now().UTC().Truncate(time.Second)
+		scrobbles, err := db.GetScrobbles("WHERE service = ? AND status = ? AND next_attempt <= ? ORDER BY timestamp LIMIT ?",
+			target.Name(), database.ScrobblePending, now, size)
+		if err != nil {
+			return err
+		}
+
+		if len(scrobbles) == 0 {
+			return nil
+		}
+
+		if err = submitBatch(db, target, scrobbles); err != nil {
+			return err
+		}
+	}
+}
+
+// Submit a batch of scrobbles, removing accepted ones from the queue
+func submitBatch(db *database.DB, target Scrobbler, scrobbles []*database.Scrobble) error {
+	results, err := target.Scrobble(scrobbles)
+	if err != nil && isPermanent(err) {
+		if len(scrobbles) == 1 {
+			log.Printf("%s rejected scrobble of %s: %v\n", target.Name(), scrobbles[0].Track, err)
+			return reject(db, scrobbles[0], err.Error())
+		}
+
+		// Submit the batch one by one so a single bad scrobble doesn't take the rest down with it
+		for _, s := range scrobbles {
+			if err := submitBatch(db, target, []*database.Scrobble{s}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		return retryLater(db, target, scrobbles, err)
+	}
+
+	var accepted []int64
+	for i, result := range results {
+		s := scrobbles[i]
+		switch {
+		case result == nil:
+			accepted = append(accepted, s.ID)
+			if err = markScrobbled(db, s); err != nil {
+				log.Println(err)
+			}
+		case isPermanent(result):
+			log.Printf("%s ignored scrobble of %s: %v\n", target.Name(), s.Track, result)
+			if err = reject(db, s, result.Error()); err != nil {
+				return err
+			}
+		default:
+			if err = retryLater(db, target, []*database.Scrobble{s}, result); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+
+	return db.RemoveScrobbles(accepted)
+}
+
+// Record the accepted scrobble on its listen
+func markScrobbled(db *database.DB, scrobble *database.Scrobble) error {
+	if scrobble.ListenID == 0 {
+		return nil
+	}
+	return db.MarkListenScrobbled(scrobble.ListenID, scrobble.Service)
+}
+
+// Keep a scrobble the service refused, along with its reason
+func reject(db *database.DB, scrobble *database.Scrobble, reason string) error {
+	scrobble.Status = database.ScrobbleRejected
+	scrobble.Attempts++
+	scrobble.Error = sql.NullString{String: reason, Valid: true}
+	return db.UpdateScrobble(scrobble, []string{"status", "attempts", "error"}, "scrobble_id", scrobble.ID)
+}
+
+// Record a failed submission and schedule the scrobbles for another attempt
+func retryLater(db *database.DB, target Scrobbler, scrobbles []*database.Scrobble, cause error) error {
+	for _, s := range scrobbles {
+		s.Attempts++
+		s.NextAttempt = time.Now().UTC().Truncate(time.Second).Add(backoff(s.Attempts))
+		s.Error = sql.NullString{String: cause.Error(), Valid: true}
+		if err := db.UpdateScrobble(s, []string{"attempts", "next_attempt", "error"}, "scrobble_id", s.ID); err != nil {
+			return err
+		}
+	}
+
+	return fmt.Errorf("failed to submit %d scrobble(s) to %s, retrying later: %v", len(scrobbles), target.Name(), cause)
+}
+
+// Delay before the next attempt, doubling with every failed attempt
+func backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
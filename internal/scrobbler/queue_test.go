@@ -0,0 +1,118 @@
+package scrobbler
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/filesystem"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+	"gitlab.com/AlexJarrah/media-manager/internal/providers/lastfm"
+)
+
+// Fails the whole batch with err while it holds a scrobble of a track in
+// bad, and otherwise accepts every scrobble
+type failingScrobbler struct {
+	err error
+	bad string
+}
+
+func (s *failingScrobbler) Name() string { return "failing" }
+
+func (s *failingScrobbler) NowPlaying(player players.Player) error { return nil }
+
+func (s *failingScrobbler) Scrobble(scrobbles []*database.Scrobble) ([]error, error) {
+	for _, scrobble := range scrobbles {
+		if s.bad == "" || scrobble.Track == s.bad {
+			return nil, classify(s.err)
+		}
+	}
+	return make([]error, len(scrobbles)), nil
+}
+
+func (s *failingScrobbler) Love(player players.Player) error { return nil }
+
+// Open a temporary database holding a pending scrobble of each track
+func newTestQueue(t *testing.T, tracks ...string) *database.DB {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	configDir, err := filesystem.GetConfigDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(configDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(filepath.Join(configDir, "config.json"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	database.Path = filepath.Join(home, "data.db")
+	t.Cleanup(func() { database.Path = "" })
+	if err = database.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.NewDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var scrobbles []*database.Scrobble
+	for i, track := range tracks {
+		scrobbles = append(scrobbles, &database.Scrobble{
+			Service:   "failing",
+			Artist:    "Artist",
+			Track:     track,
+			Timestamp: time.Date(2024, 1, 1, 12, i, 0, 0, time.UTC),
+			Status:    database.ScrobblePending,
+		})
+	}
+	if err = db.AddScrobbles(scrobbles); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestSubmitBatchErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   *failingScrobbler
+		pending  int // Scrobbles left to retry
+		rejected int
+	}{
+		{"account error", &failingScrobbler{err: &lastfm.APIError{Code: lastfm.ErrorInvalidAPIKey}}, 3, 0},
+		{"suspended API key", &failingScrobbler{err: &lastfm.APIError{Code: lastfm.ErrorSuspendedAPIKey}}, 3, 0},
+		{"network error", &failingScrobbler{err: errors.New("connection reset")}, 3, 0},
+		{"bad scrobble", &failingScrobbler{err: &lastfm.APIError{Code: lastfm.ErrorInvalidParameters}, bad: "Second"}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestQueue(t, "First", "Second", "Third")
+
+			scrobbles, err := db.GetScrobbles("ORDER BY timestamp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			submitBatch(db, tt.target, scrobbles)
+
+			for status, want := range map[string]int{database.ScrobblePending: tt.pending, database.ScrobbleRejected: tt.rejected} {
+				got, err := db.GetScrobbles("WHERE status = ?", status)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != want {
+					t.Errorf("got %d %s scrobbles, want %d", len(got), status, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package scrobbler
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+)
+
+// Standard scrobbling rules, as used by Last.fm and ListenBrainz
+const (
+	defaultMinTrackSeconds      = 30
+	defaultMinPlayedPercent     = 50
+	defaultMaxRequiredSeconds   = 4 * 60
+	defaultUnknownLengthSeconds = 4 * 60
+)
+
+// Rules that allow or block a listen
+const (
+	RuleTrackTooShort          = "track_too_short"
+	RulePlayedPercent          = "played_percent"
+	RulePlayedMaxRequired      = "played_max_required"
+	RuleNotPlayedEnough        = "not_played_enough"
+	RuleUnknownLengthPlayed    = "unknown_length_played"
+	RuleUnknownLengthNotPlayed = "unknown_length_not_played_enough"
+)
+
+// Decision is the outcome of applying the scrobble rules to a listen
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule"`   // Rule that allowed or blocked the listen
+	Reason  string `json:"reason"` // Human readable explanation
+}
+
+// Fill in defaults for unset rules
+func withDefaults(rules internal.ScrobbleRules) internal.ScrobbleRules {
+	if rules.MinTrackSeconds == 0 {
+		rules.MinTrackSeconds = defaultMinTrackSeconds
+	}
+	if rules.MinPlayedPercent == 0 {
+		rules.MinPlayedPercent = defaultMinPlayedPercent
+	}
+	if rules.MaxRequiredSeconds == 0 {
+		rules.MaxRequiredSeconds = defaultMaxRequiredSeconds
+	}
+	if rules.UnknownLengthSeconds == 0 {
+		rules.UnknownLengthSeconds = defaultUnknownLengthSeconds
+	}
+	return rules
+}
+
+// Decide whether a listen is scrobbled. A track must be longer than the minimum length,
+// and played for either the minimum share of its length or the maximum required play
+// time, whichever comes first. Tracks of unknown length (0) use a fixed play time instead.
+func Eligible(rules internal.ScrobbleRules, length, played time.Duration) Decision {
+	rules = withDefaults(rules)
+	seconds := func(n int) time.Duration { return time.Duration(n) * time.Second }
+
+	if length <= 0 {
+		required := seconds(rules.UnknownLengthSeconds)
+		if played >= required {
+			return Decision{true, RuleUnknownLengthPlayed, fmt.Sprintf("length unknown, played %.0fs of the required %.0fs", played.Seconds(), required.Seconds())}
+		}
+		return Decision{false, RuleUnknownLengthNotPlayed, fmt.Sprintf("length unknown, played %.0fs of the required %.0fs", played.Seconds(), required.Seconds())}
+	}
+
+	if length <= seconds(rules.MinTrackSeconds) {
+		return Decision{false, RuleTrackTooShort, fmt.Sprintf("track length %.0fs is not longer than %ds", length.Seconds(), rules.MinTrackSeconds)}
+	}
+
+	byPercent := time.Duration(float64(length) * rules.MinPlayedPercent / 100)
+	if maxRequired := seconds(rules.MaxRequiredSeconds); maxRequired < byPercent {
+		if played >= maxRequired {
+			return Decision{true, RulePlayedMaxRequired, fmt.Sprintf("played %.0fs, at least %ds", played.Seconds(), rules.MaxRequiredSeconds)}
+		}
+		return Decision{false, RuleNotPlayedEnough, fmt.Sprintf("played %.0fs of the required %ds", played.Seconds(), rules.MaxRequiredSeconds)}
+	}
+
+	if played >= byPercent {
+		return Decision{true, RulePlayedPercent, fmt.Sprintf("played %.0fs, at least %.0f%% of %.0fs", played.Seconds(), rules.MinPlayedPercent, length.Seconds())}
+	}
+	return Decision{false, RuleNotPlayedEnough, fmt.Sprintf("played %.0fs of the required %.0fs (%.0f%% of %.0fs)", played.Seconds(), byPercent.Seconds(), rules.MinPlayedPercent, length.Seconds())}
+}
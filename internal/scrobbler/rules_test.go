@@ -0,0 +1,72 @@
+package scrobbler
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+)
+
+func TestEligible(t *testing.T) {
+	s := func(n float64) time.Duration { return time.Duration(n * float64(time.Second)) }
+
+	tests := []struct {
+		name    string
+		rules   internal.ScrobbleRules
+		length  time.Duration
+		played  time.Duration
+		allowed bool
+		rule    string
+	}{
+		{"track of 30s is too short", internal.ScrobbleRules{}, s(30), s(30), false, RuleTrackTooShort},
+		{"track under 30s is too short", internal.ScrobbleRules{}, s(20), s(20), false, RuleTrackTooShort},
+		{"track just over 30s", internal.ScrobbleRules{}, s(31), s(15.5), true, RulePlayedPercent},
+		{"half of a short track", internal.ScrobbleRules{}, s(200), s(100), true, RulePlayedPercent},
+		{"under half of a short track", internal.ScrobbleRules{}, s(200), s(99), false, RuleNotPlayedEnough},
+		{"4 minutes of a long track", internal.ScrobbleRules{}, s(600), s(240), true, RulePlayedMaxRequired},
+		{"under 4 minutes of a long track", internal.ScrobbleRules{}, s(600), s(239), false, RuleNotPlayedEnough},
+		{"half equals 4 minutes", internal.ScrobbleRules{}, s(480), s(240), true, RulePlayedPercent},
+		{"unknown length played 4 minutes", internal.ScrobbleRules{}, 0, s(240), true, RuleUnknownLengthPlayed},
+		{"unknown length played under 4 minutes", internal.ScrobbleRules{}, 0, s(239), false, RuleUnknownLengthNotPlayed},
+		{"custom minimum length", internal.ScrobbleRules{MinTrackSeconds: 60}, s(45), s(45), false, RuleTrackTooShort},
+		{"custom percent", internal.ScrobbleRules{MinPlayedPercent: 90}, s(200), s(150), false, RuleNotPlayedEnough},
+		{"custom maximum required", internal.ScrobbleRules{MaxRequiredSeconds: 60}, s(600), s(60), true, RulePlayedMaxRequired},
+		{"custom unknown length", internal.ScrobbleRules{UnknownLengthSeconds: 30}, 0, s(30), true, RuleUnknownLengthPlayed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := Eligible(tt.rules, tt.length, tt.played)
+			if decision.Allowed != tt.allowed || decision.Rule != tt.rule {
+				t.Errorf("Eligible(%v, %v) = %v %s (%s), want %v %s", tt.length, tt.played,
+					decision.Allowed, decision.Rule, decision.Reason, tt.allowed, tt.rule)
+			}
+		})
+	}
+}
+
+func TestTargetRules(t *testing.T) {
+	strict := internal.ScrobbleRules{MinPlayedPercent: 90}
+	config := internal.Config{
+		ScrobbleRules: internal.ScrobbleRules{MaxRequiredSeconds: 60},
+		Scrobblers: []internal.Scrobbler{
+			{Name: "default", Type: TypeListenBrainz, Enabled: true, ListenBrainz: &internal.ListenBrainz{}},
+			{Name: "strict", Type: TypeListenBrainz, Enabled: true, ListenBrainz: &internal.ListenBrainz{}, Rules: &strict},
+			{Name: "disabled", Type: TypeListenBrainz, ListenBrainz: &internal.ListenBrainz{}},
+		},
+	}
+
+	want := map[string]bool{"default": true, "strict": false}
+	targets := Targets(config)
+	if len(targets) != len(want) {
+		t.Fatalf("got %d targets, want %d", len(targets), len(want))
+	}
+
+	// 100s of a 200s track: enough for the top-level rules, not for 90%
+	for _, target := range targets {
+		decision := Eligible(target.Rules, 200*time.Second, 100*time.Second)
+		if decision.Allowed != want[target.Name()] {
+			t.Errorf("%s: allowed = %v (%s), want %v", target.Name(), decision.Allowed, decision.Reason, want[target.Name()])
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package scrobbler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+	"gitlab.com/AlexJarrah/media-manager/internal/database"
+	"gitlab.com/AlexJarrah/media-manager/internal/players"
+)
+
+// Scrobbler is a service that listens are submitted to
+type Scrobbler interface {
+	// Unique name of the target, used to track its queued scrobbles
+	Name() string
+
+	// Announce a newly started track
+	NowPlaying(player players.Player) error
+
+	// Submit a batch of queued scrobbles. The returned slice holds the outcome of each
+	// scrobble (nil when accepted), the error is set when the whole batch failed.
+	// Wrap errors in PermanentError when retrying cannot succeed.
+	Scrobble(scrobbles []*database.Scrobble) ([]error, error)
+
+	// Mark a track as loved
+	Love(player players.Player) error
+}
+
+// Target is a configured scrobbler along with the rules deciding which listens it receives
+type Target struct {
+	Scrobbler
+	Rules internal.ScrobbleRules
+}
+
+// Scrobbler types that can be configured
+const (
+	TypeLastFM       = "lastfm"
+	TypeListenBrainz = "listenbrainz"
+)
+
+// PermanentError marks a rejection that won't succeed if retried
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Reports whether the error is a rejection that won't succeed if retried
+func isPermanent(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
+
+// Returns every enabled scrobbling target; replaced in tests to observe submissions
+var Targets = configuredTargets
+
+// Returns the scrobbling targets set up in the config. The top-level Last.fm and
+// ListenBrainz settings act as targets named after their service when they are
+// filled out, unless an enabled scrobbler has that name.
+func configuredTargets(config internal.Config) (targets []Target) {
+	names := make(map[string]bool)
+	for _, s := range config.Scrobblers {
+		if !s.Enabled {
+			continue
+		}
+		names[s.Name] = true
+
+		target, err := newTarget(s)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		rules := config.ScrobbleRules
+		if s.Rules != nil {
+			rules = *s.Rules
+		}
+		targets = append(targets, Target{target, rules})
+	}
+
+	if config.LastFM.APIKey != "" && !names[TypeLastFM] {
+		targets = append(targets, Target{&lastFMScrobbler{name: TypeLastFM, config: config.LastFM}, config.ScrobbleRules})
+	}
+	if config.ListenBrainz.Token != "" && !names[TypeListenBrainz] {
+		targets = append(targets, Target{&listenBrainzScrobbler{name: TypeListenBrainz, config: config.ListenBrainz}, config.ScrobbleRules})
+	}
+
+	return targets
+}
+
+// Returns the Last.fm-compatible target with the given name, including the top-level one
+func LastFMTarget(config internal.Config, name string) (internal.LastFM, error) {
+	for _, s := range config.Scrobblers {
+		if s.Name == name && s.Type == TypeLastFM && s.LastFM != nil {
+			return *s.LastFM, nil
+		}
+	}
+
+	if name == TypeLastFM {
+		return config.LastFM, nil
+	}
+
+	return internal.LastFM{}, fmt.Errorf("no Last.fm-compatible scrobbler named %s", name)
+}
+
+func newTarget(s internal.Scrobbler) (Scrobbler, error) {
+	if s.Name == "" {
+		return nil, fmt.Errorf("scrobbler of type %s has no name", s.Type)
+	}
+
+	switch s.Type {
+	case TypeLastFM:
+		if s.LastFM == nil {
+			return nil, fmt.Errorf("scrobbler %s has no lastfm settings", s.Name)
+		}
+		return &lastFMScrobbler{name: s.Name, config: *s.LastFM}, nil
+	case TypeListenBrainz:
+		if s.ListenBrainz == nil {
+			return nil, fmt.Errorf("scrobbler %s has no listenbrainz settings", s.Name)
+		}
+		return &listenBrainzScrobbler{name: s.Name, config: *s.ListenBrainz}, nil
+	default:
+		return nil, fmt.Errorf("scrobbler %s has unknown type: %s", s.Name, s.Type)
+	}
+}
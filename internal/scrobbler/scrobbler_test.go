@@ -0,0 +1,38 @@
+package scrobbler
+
+import (
+	"reflect"
+	"testing"
+
+	"gitlab.com/AlexJarrah/media-manager/internal"
+)
+
+func TestConfiguredTargets(t *testing.T) {
+	other := &internal.LastFM{Username: "other", APIKey: "other"}
+
+	// Targets as name/username, as every target here is Last.fm-compatible
+	tests := []struct {
+		name       string
+		scrobblers []internal.Scrobbler
+		want       []string
+	}{
+		{"top-level only", nil, []string{"lastfm/user"}},
+		{"enabled override", []internal.Scrobbler{{Name: "lastfm", Type: TypeLastFM, Enabled: true, LastFM: other}}, []string{"lastfm/other"}},
+		{"disabled override", []internal.Scrobbler{{Name: "lastfm", Type: TypeLastFM, LastFM: other}}, []string{"lastfm/user"}},
+		{"other name", []internal.Scrobbler{{Name: "libre.fm", Type: TypeLastFM, Enabled: true, LastFM: other}}, []string{"libre.fm/other", "lastfm/user"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := internal.Config{LastFM: internal.LastFM{Username: "user", APIKey: "key"}, Scrobblers: tt.scrobblers}
+
+			var got []string
+			for _, target := range configuredTargets(config) {
+				got = append(got, target.Name()+"/"+target.Scrobbler.(*lastFMScrobbler).config.Username)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got targets %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,19 +1,81 @@
 package internal
 
 type Config struct {
-	Players          []string `json:"players"`
-	MediaDirectories []string `json:"media_directories"`
-	LastFM           LastFM   `json:"lastfm"`
-	Discord          Discord  `json:"discord"`
+	Players          []string                  `json:"players"`
+	PlayerSettings   map[string]PlayerSettings `json:"player_settings"` // Keyed by player name, e.g. org.mpris.MediaPlayer2.firefox
+	MediaDirectories []string                  `json:"media_directories"`
+	LastFM           LastFM                    `json:"lastfm"`
+	ListenBrainz     ListenBrainz              `json:"listenbrainz"`
+	Scrobblers       []Scrobbler               `json:"scrobblers"`
+	ScrobbleRules    ScrobbleRules             `json:"scrobble_rules"`
+	MusicBrainz      MusicBrainz               `json:"musicbrainz"`
+	Discord          Discord                   `json:"discord"`
+}
+
+type MusicBrainz struct {
+	APIURL      string `json:"api_url,omitempty"`       // Defaults to musicbrainz.org, set for mirrors
+	CoverArtURL string `json:"cover_art_url,omitempty"` // Defaults to the Cover Art Archive
+}
+
+// Workarounds for players with incomplete MPRIS support, e.g. browsers & Electron apps
+type PlayerSettings struct {
+	PollSeconds    int `json:"poll_seconds"` // Read the player's properties on this interval, 0 disables polling
+	DebounceMillis int `json:"debounce_ms"`  // Wait for metadata to settle this long before changing tracks, 0 disables
 }
 
 type LastFM struct {
 	Username  string `json:"username"`
-	Password  string `json:"password"`
+	Password  string `json:"password,omitempty"` // Optional, prefer the `auth lastfm` command
 	APIKey    string `json:"api_key"`
 	APISecret string `json:"api_secret"`
+	APIURL    string `json:"api_url,omitempty"`  // Defaults to the Last.fm API
+	AuthURL   string `json:"auth_url,omitempty"` // Defaults to the Last.fm authorization page
+}
+
+type ListenBrainz struct {
+	Token  string `json:"token"`
+	APIURL string `json:"api_url,omitempty"` // Defaults to listenbrainz.org, set for self-hosted instances
+}
+
+// Additional scrobbling target, e.g. a second account or a Libre.fm server
+type Scrobbler struct {
+	Name         string         `json:"name"`            // Unique name, used to track the target's scrobbles
+	Type         string         `json:"type"`            // "lastfm" for Last.fm-compatible servers, or "listenbrainz"
+	Enabled      bool           `json:"enabled"`         // Disabled targets receive no listens
+	Rules        *ScrobbleRules `json:"rules,omitempty"` // Overrides the top-level scrobble rules
+	LastFM       *LastFM        `json:"lastfm,omitempty"`
+	ListenBrainz *ListenBrainz  `json:"listenbrainz,omitempty"`
+}
+
+// Rules deciding whether a listen is scrobbled; zero values use the standard defaults
+type ScrobbleRules struct {
+	MinTrackSeconds      int     `json:"min_track_seconds"`      // Tracks must be longer than this (default 30)
+	MinPlayedPercent     float64 `json:"min_played_percent"`     // Share of the track that must be played (default 50)
+	MaxRequiredSeconds   int     `json:"max_required_seconds"`   // Play time that always suffices (default 240)
+	UnknownLengthSeconds int     `json:"unknown_length_seconds"` // Play time required when the length is unknown (default 240)
 }
 
 type Discord struct {
-	ClientID string `json:"client_id"`
+	ClientID  string            `json:"client_id"`
+	Presence  PresenceTemplates `json:"presence"`  // Empty fields show the track's title, artists & album
+	Anonymous PresenceTemplates `json:"anonymous"` // Shown instead for anonymized tracks
+	Rules     []PresenceRule    `json:"rules"`     // The first matching rule applies
+	Buttons   []string          `json:"buttons"`   // Links to the track: "musicbrainz" and/or "lastfm"
+}
+
+// Go text/template strings for the presence fields, e.g. "{{.Title}} by {{.Artist}}"
+type PresenceTemplates struct {
+	Details    string `json:"details,omitempty"`
+	State      string `json:"state,omitempty"`
+	LargeText  string `json:"large_text,omitempty"`
+	LargeImage string `json:"large_image,omitempty"`
+	SmallText  string `json:"small_text,omitempty"`
+	SmallImage string `json:"small_image,omitempty"`
+}
+
+// Hides or anonymizes the presence of tracks from certain players or with certain tags
+type PresenceRule struct {
+	Players []string `json:"players,omitempty"` // Player names, e.g. spotify; empty matches every player
+	Tags    []string `json:"tags,omitempty"`    // Genres or library tags, e.g. audiobook; empty matches every track
+	Action  string   `json:"action"`            // "hide", "anonymize" or "show"
 }